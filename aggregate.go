@@ -0,0 +1,188 @@
+package customdb
+
+import (
+	"bytes"
+	"math"
+	"time"
+)
+
+type AggregateFunc int
+
+const (
+	AggregateCount AggregateFunc = iota
+	AggregateSum
+	AggregateMin
+	AggregateMax
+	AggregateAvg
+)
+
+// Bucket is the aggregate of the points of one time bucket. Value is the result of the AggregateFunc the query asked
+// for, the other statistics of the bucket are always filled.
+type Bucket struct {
+	Start time.Time
+	Value float64
+
+	Count uint64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// aggregate holds the running statistics of a bucket
+type aggregate struct {
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (a *aggregate) add(value float64) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.count += 1
+	a.sum += value
+}
+
+func (a *aggregate) merge(other aggregate) {
+	if other.count == 0 {
+		return
+	}
+	if a.count == 0 || other.min < a.min {
+		a.min = other.min
+	}
+	if a.count == 0 || other.max > a.max {
+		a.max = other.max
+	}
+	a.count += other.count
+	a.sum += other.sum
+}
+
+func (a *aggregate) result(fn AggregateFunc) float64 {
+	switch fn {
+	case AggregateCount:
+		return float64(a.count)
+	case AggregateSum:
+		return a.sum
+	case AggregateMin:
+		return a.min
+	case AggregateMax:
+		return a.max
+	case AggregateAvg:
+		if a.count == 0 {
+			return math.NaN()
+		}
+		return a.sum / float64(a.count)
+	}
+	return math.NaN()
+}
+
+// bucketStart returns the start of the bucket the timestamp falls into. Buckets are aligned to the unix epoch.
+func bucketStart(timestamp time.Time, bucket time.Duration) time.Time {
+	nanos := timestamp.UnixNano()
+	offset := nanos % int64(bucket)
+	if offset < 0 {
+		offset += int64(bucket)
+	}
+	return time.Unix(0, nanos-offset)
+}
+
+// Aggregate returns the points of a series in [from, to) grouped into buckets of the given size. The points are read
+// with a cursor in key order, so only the bucket being filled is kept in memory. Buckets without points are skipped.
+// If the collection has a rollup whose buckets fit into the requested ones, the pre-aggregated rollup is read instead
+// of the raw points.
+func (c *Collection) Aggregate(series []byte, from, to time.Time, bucket time.Duration, fn AggregateFunc) ([]Bucket, error) {
+	if bucket <= 0 {
+		return nil, invalidBucketErr
+	}
+
+	source, decode := c, decodePointAggregate
+	if r := c.rollupFor(from, to, bucket); r != nil {
+		source, decode = r.collection(), deserializeAggregate
+	}
+
+	aggregates, err := source.aggregateRange(series, from, to, bucket, decode)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]Bucket, 0, len(aggregates))
+	for _, a := range aggregates {
+		buckets = append(buckets, a.bucket(fn))
+	}
+	return buckets, nil
+}
+
+// timedAggregate is the aggregate of the bucket starting at start
+type timedAggregate struct {
+	start time.Time
+	aggregate
+}
+
+func (a *timedAggregate) bucket(fn AggregateFunc) Bucket {
+	return Bucket{
+		Start: a.start,
+		Value: a.result(fn),
+		Count: a.count,
+		Sum:   a.sum,
+		Min:   a.min,
+		Max:   a.max,
+	}
+}
+
+func decodePointAggregate(value []byte) (aggregate, error) {
+	var a aggregate
+	point, err := decodePointValue(value)
+	if err != nil {
+		return a, err
+	}
+	a.add(point)
+	return a, nil
+}
+
+// aggregateRange merges the aggregates decoded from the items of a series in [from, to) into buckets
+func (c *Collection) aggregateRange(series []byte, from, to time.Time, bucket time.Duration, decode func([]byte) (aggregate, error)) ([]timedAggregate, error) {
+	fromKey, err := encodePointKey(series, from)
+	if err != nil {
+		return nil, err
+	}
+	toKey, err := encodePointKey(series, to)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := make([]timedAggregate, 0)
+	var current timedAggregate
+
+	cursor := c.Cursor()
+	item, err := cursor.Seek(fromKey)
+	for ; err == nil && item != nil && bytes.Compare(item.Key, toKey) < 0; item, err = cursor.Next() {
+		_, timestamp, decodeErr := decodePointKey(item.Key)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		a, decodeErr := decode(item.Value)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		start := bucketStart(timestamp, bucket)
+		if current.count != 0 && !start.Equal(current.start) {
+			aggregates = append(aggregates, current)
+			current = timedAggregate{}
+		}
+		current.start = start
+		current.merge(a)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.count != 0 {
+		aggregates = append(aggregates, current)
+	}
+	return aggregates, nil
+}
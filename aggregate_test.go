@@ -0,0 +1,90 @@
+package customdb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollection_Aggregate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	// three minutes of points valued 0 to 179, one a second
+	putTestPoints(t, db, start, 180)
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		// points of another series in the same buckets
+		return collection.PutPoint([]byte("mem"), start.Add(30*time.Second), 1000)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type bucket struct {
+		start time.Duration
+		count uint64
+		sum   float64
+	}
+	tests := []struct {
+		name     string
+		from, to time.Duration
+		bucket   time.Duration
+		expected []bucket
+	}{
+		{name: "empty range", from: time.Minute, to: time.Minute, bucket: time.Minute},
+		{name: "before the points", from: -time.Hour, to: 0, bucket: time.Minute},
+		{name: "after the points", from: 3 * time.Minute, to: time.Hour, bucket: time.Minute},
+		{
+			name: "whole buckets", from: 0, to: 3 * time.Minute, bucket: time.Minute,
+			expected: []bucket{{0, 60, 1770}, {time.Minute, 60, 5370}, {2 * time.Minute, 60, 8970}},
+		},
+		{
+			// the first bucket starts before the range, but only has the points in it
+			name: "starts inside a bucket", from: 90 * time.Second, to: 3 * time.Minute, bucket: time.Minute,
+			expected: []bucket{{time.Minute, 30, 3135}, {2 * time.Minute, 60, 8970}},
+		},
+		{
+			name: "ends inside a bucket", from: 0, to: 70 * time.Second, bucket: time.Minute,
+			expected: []bucket{{0, 60, 1770}, {time.Minute, 10, 645}},
+		},
+		{
+			name: "one point", from: 10 * time.Second, to: 11 * time.Second, bucket: time.Hour,
+			expected: []bucket{{0, 1, 10}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := db.View(func(tx *Tx) error {
+				collection, err := tx.GetCollection(testCollectionName)
+				if err != nil {
+					return err
+				}
+				buckets, err := collection.Aggregate(testSeries, start.Add(test.from), start.Add(test.to), test.bucket,
+					AggregateSum)
+				if err != nil {
+					return err
+				}
+
+				if len(buckets) != len(test.expected) {
+					t.Fatalf("expected %d buckets, got %v", len(test.expected), buckets)
+				}
+				for i, expected := range test.expected {
+					b := buckets[i]
+					if !b.Start.Equal(start.Add(expected.start)) || b.Count != expected.count || b.Sum != expected.sum ||
+						b.Value != expected.sum {
+						t.Errorf("expected bucket %d to start at %v with %d points summing to %v, got %+v", i,
+							start.Add(expected.start), expected.count, expected.sum, b)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Batch and its retry of failed calls are ported from DB.Batch of bbolt (https://github.com/etcd-io/bbolt), which is
+// distributed under the following license:
+//
+// The MIT License (MIT)
+//
+// Copyright (c) 2013 Ben Johnson
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package customdb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type batchCall struct {
+	fn  func(*Tx) error
+	err chan<- error
+}
+
+// batch is a group of Batch calls that are run in a single write transaction
+type batch struct {
+	db    *DB
+	timer *time.Timer
+	start sync.Once
+	calls []batchCall
+}
+
+// Batch runs fn in a write transaction shared with other Batch calls that arrive within MaxBatchDelay, up to
+// MaxBatchSize calls, so concurrent small writes pay for one commit together. If fn fails, the shared transaction is
+// rolled back, the other functions run again without it and fn is run again in its own transaction, so fn may be
+// called more than once and must not have side effects outside the transaction.
+func (db *DB) Batch(fn func(*Tx) error) error {
+	errCh := make(chan error, 1)
+
+	db.batchMu.Lock()
+	if db.batch == nil || len(db.batch.calls) >= db.maxBatchSize {
+		// the previous batch is full or already running
+		db.batch = &batch{
+			db: db,
+		}
+		db.batch.timer = time.AfterFunc(db.maxBatchDelay, db.batch.trigger)
+	}
+	db.batch.calls = append(db.batch.calls, batchCall{fn: fn, err: errCh})
+	if len(db.batch.calls) >= db.maxBatchSize {
+		go db.batch.trigger()
+	}
+	db.batchMu.Unlock()
+
+	err := <-errCh
+	if err == trySoloErr {
+		err = db.Update(fn)
+	}
+	return err
+}
+
+func (b *batch) trigger() {
+	b.start.Do(b.run)
+}
+
+func (b *batch) run() {
+	b.db.batchMu.Lock()
+	b.timer.Stop()
+	// new calls start another batch from now on
+	if b.db.batch == b {
+		b.db.batch = nil
+	}
+	b.db.batchMu.Unlock()
+
+	for len(b.calls) > 0 {
+		failIdx := -1
+		err := b.db.Update(func(tx *Tx) error {
+			for i, call := range b.calls {
+				err := safelyCall(call.fn, tx)
+				if err != nil {
+					failIdx = i
+					return err
+				}
+			}
+			return nil
+		})
+
+		if failIdx >= 0 {
+			// take the failed call out and run the others again
+			call := b.calls[failIdx]
+			b.calls[failIdx] = b.calls[len(b.calls)-1]
+			b.calls = b.calls[:len(b.calls)-1]
+			call.err <- trySoloErr
+			continue
+		}
+
+		for _, call := range b.calls {
+			call.err <- err
+		}
+		return
+	}
+}
+
+// safelyCall turns a panic of fn into an error, so one function can't take down the whole batch
+func safelyCall(fn func(*Tx) error, tx *Tx) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("batch function panicked: %v", p)
+		}
+	}()
+	return fn(tx)
+}
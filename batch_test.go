@@ -0,0 +1,184 @@
+package customdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestBatchDB opens a database whose batches start once they have size calls, the delay is long enough to never
+// start one in the tests
+func openTestBatchDB(t *testing.T, size int) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "db"), &Params{
+		MinStored:     testMinPercentage,
+		MaxStored:     testMaxPercentage,
+		MaxBatchSize:  size,
+		MaxBatchDelay: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func putTestBatchItem(tx *Tx, key string) error {
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		return err
+	}
+	return collection.Put([]byte(key), []byte(key))
+}
+
+func commitsOf(t *testing.T, db *DB) int64 {
+	t.Helper()
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats.Commits
+}
+
+// runTestBatch runs the functions in concurrent Batch calls and returns their errors
+func runTestBatch(db *DB, fns ...func(*Tx) error) []error {
+	errs := make([]error, len(fns))
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		go func(i int, fn func(*Tx) error) {
+			defer wg.Done()
+			errs[i] = db.Batch(fn)
+		}(i, fn)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestBatch_Coalesce(t *testing.T) {
+	const calls = 10
+	db := openTestBatchDB(t, calls)
+	defer db.Close()
+	commits := commitsOf(t, db)
+
+	var mu sync.Mutex
+	txs := map[*Tx]bool{}
+	fns := make([]func(*Tx) error, 0, calls)
+	for i := 0; i < calls; i++ {
+		key := fmt.Sprint(i)
+		fns = append(fns, func(tx *Tx) error {
+			mu.Lock()
+			txs[tx] = true
+			mu.Unlock()
+			return putTestBatchItem(tx, key)
+		})
+	}
+	for i, err := range runTestBatch(db, fns...) {
+		if err != nil {
+			t.Errorf("call %d: %v", i, err)
+		}
+	}
+
+	if len(txs) != 1 {
+		t.Errorf("expected the calls to share a transaction, they ran in %d", len(txs))
+	}
+	if commitsOf(t, db)-commits != 1 {
+		t.Errorf("expected a single commit, got %d", commitsOf(t, db)-commits)
+	}
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < calls; i++ {
+			item, err := collection.Find([]byte(fmt.Sprint(i)))
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				t.Errorf("item %d of the batch is missing", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatch_RetryFailedAlone(t *testing.T) {
+	db := openTestBatchDB(t, 3)
+	defer db.Close()
+	commits := commitsOf(t, db)
+
+	errFail := errors.New("fail")
+	var mu sync.Mutex
+	calls := map[string]int{}
+	batchFn := func(key string, fail func(call int) bool) func(*Tx) error {
+		return func(tx *Tx) error {
+			mu.Lock()
+			calls[key]++
+			call := calls[key]
+			mu.Unlock()
+
+			err := putTestBatchItem(tx, key)
+			if err != nil {
+				return err
+			}
+			if fail(call) {
+				return errFail
+			}
+			return nil
+		}
+	}
+
+	errs := runTestBatch(db,
+		batchFn("ok", func(int) bool { return false }),
+		// fails in the shared transaction only
+		batchFn("retried", func(call int) bool { return call == 1 }),
+		batchFn("failed", func(int) bool { return true }),
+	)
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("expected the other calls to succeed, got %v and %v", errs[0], errs[1])
+	}
+	if !errors.Is(errs[2], errFail) {
+		t.Errorf("expected the error of the failing function, got %v", errs[2])
+	}
+	// each failure takes a function out of the batch, which runs once more alone
+	if calls["retried"] != 2 || calls["failed"] != 2 {
+		t.Errorf("expected the failed functions to run twice, got %v", calls)
+	}
+	// the batch without the failed functions and the retried function alone
+	if commitsOf(t, db)-commits != 2 {
+		t.Errorf("expected 2 commits, got %d", commitsOf(t, db)-commits)
+	}
+
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for key, expected := range map[string]bool{"ok": true, "retried": true, "failed": false} {
+			item, err := collection.Find([]byte(key))
+			if err != nil {
+				return err
+			}
+			if (item != nil) != expected {
+				t.Errorf("expected item %s to exist: %v", key, expected)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,212 @@
+package customdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Violation is an inconsistency of the database file found by DB.Check.
+type Violation struct {
+	// Page is the page the violation was found on, 0 for the meta page or problems without a page
+	Page uint64
+	// Tree is the tree the page belongs to, like "collection users" or "index by-email of collection users"
+	Tree    string
+	Problem string
+}
+
+func (v Violation) String() string {
+	if v.Tree == "" {
+		return fmt.Sprintf("page %d: %s", v.Page, v.Problem)
+	}
+	return fmt.Sprintf("page %d (%s): %s", v.Page, v.Tree, v.Problem)
+}
+
+// checker walks every tree of the file in a read transaction and collects what's wrong with it
+type checker struct {
+	tx         *Tx
+	violations []Violation
+	// the tree every reachable page belongs to
+	owners map[pgnum]string
+}
+
+// Check reads the whole database file and returns every inconsistency found: keys out of order within and across
+// nodes, wrong child counts, pages referenced twice, pages neither reachable nor free, empty nodes, nodes above the
+// upper fill bound and collection headers that can't be decoded. The fill bound is the one of the Params the database
+// was opened with, since the file doesn't store it. A nil slice means the file is consistent.
+func (db *DB) Check() ([]Violation, error) {
+	tx := db.ReadTx()
+	defer tx.Rollback()
+
+	c := &checker{
+		tx:     tx,
+		owners: map[pgnum]string{},
+	}
+	c.checkTree(tx.root, "root collection", func(page pgnum, item *Item) {
+		c.checkCollection(page, item)
+	})
+	c.checkFreelist()
+
+	sort.SliceStable(c.violations, func(i, j int) bool {
+		return c.violations[i].Page < c.violations[j].Page
+	})
+	return c.violations, nil
+}
+
+func (c *checker) report(page pgnum, tree string, format string, args ...any) {
+	c.violations = append(c.violations, Violation{
+		Page:    uint64(page),
+		Tree:    tree,
+		Problem: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkTree checks the tree with the given root page and calls visit for every item of it
+func (c *checker) checkTree(root pgnum, tree string, visit func(page pgnum, item *Item)) {
+	if root == 0 {
+		return
+	}
+	leafDepth := -1
+	c.checkNode(root, tree, nil, nil, 0, &leafDepth, visit)
+}
+
+// checkNode checks a node whose keys have to be greater than lower and smaller than upper, nil bounds are open
+func (c *checker) checkNode(page pgnum, tree string, lower []byte, upper []byte, depth int, leafDepth *int,
+	visit func(page pgnum, item *Item)) {
+	if owner, ok := c.owners[page]; ok {
+		c.report(page, tree, "page is already used by %s", owner)
+		return
+	}
+	if page == metaPageNum || page == c.tx.db.freelistPage {
+		c.report(page, tree, "node is stored on the meta or freelist page")
+		return
+	}
+	c.owners[page] = tree
+
+	node, err := c.tx.getNode(page)
+	if err != nil {
+		c.report(page, tree, "can't read node: %v", err)
+		return
+	}
+
+	isRoot := depth == 0
+	if len(node.items) == 0 && (!isRoot || !node.isLeaf()) {
+		c.report(page, tree, "node has no items")
+	}
+	if !node.isLeaf() && len(node.childNodes) != len(node.items)+1 {
+		c.report(page, tree, "node has %d items but %d children", len(node.items), len(node.childNodes))
+	}
+	// the lower bound is only a goal, a split or a single rotation on remove may leave a node below it, so only
+	// empty nodes are reported above
+	if size := node.nodeSize(); size > c.tx.db.pSize {
+		c.report(page, tree, "node of %d bytes doesn't fit its page", size)
+	} else if float32(size) > c.tx.db.maxRange() {
+		c.report(page, tree, "node fill %d bytes is above the upper bound of %.0f bytes", size, c.tx.db.maxRange())
+	}
+
+	for i, item := range node.items {
+		if i > 0 && bytes.Compare(node.items[i-1].Key, item.Key) >= 0 {
+			c.report(page, tree, "key %q at position %d isn't greater than the key before it", item.Key, i)
+		}
+		if lower != nil && bytes.Compare(item.Key, lower) <= 0 {
+			c.report(page, tree, "key %q isn't greater than the key %q of the parent", item.Key, lower)
+		}
+		if upper != nil && bytes.Compare(item.Key, upper) >= 0 {
+			c.report(page, tree, "key %q isn't smaller than the key %q of the parent", item.Key, upper)
+		}
+		visit(page, item)
+	}
+
+	if node.isLeaf() {
+		if *leafDepth == -1 {
+			*leafDepth = depth
+		} else if *leafDepth != depth {
+			c.report(page, tree, "leaf is at depth %d while other leaves are at depth %d", depth, *leafDepth)
+		}
+		return
+	}
+
+	for i, child := range node.childNodes {
+		if child > c.tx.db.maxAllowedPage {
+			c.report(page, tree, "child %d points past the end of the file to page %d", i, child)
+			continue
+		}
+		childLower, childUpper := lower, upper
+		if i > 0 && i-1 < len(node.items) {
+			childLower = node.items[i-1].Key
+		}
+		if i < len(node.items) {
+			childUpper = node.items[i].Key
+		}
+		c.checkNode(child, tree, childLower, childUpper, depth+1, leafDepth, visit)
+	}
+}
+
+// checkCollection checks the header of a collection stored in the root collection and then its trees
+func (c *checker) checkCollection(page pgnum, item *Item) {
+	name := fmt.Sprintf("collection %s", item.Key)
+	if len(item.Value) > maxHeaderSize {
+		c.report(page, "root collection", "header of %s is %d bytes", name, len(item.Value))
+	}
+
+	collection := newEmptyCollection()
+	err := collection.deserialize(item)
+	if err != nil {
+		c.report(page, "root collection", "invalid header: %v", err)
+		return
+	}
+	collection.tx = c.tx
+
+	c.checkTree(collection.rootNodePage, name, func(pgnum, *Item) {})
+
+	intervals := map[int64]bool{}
+	for _, r := range collection.rollups {
+		tree := fmt.Sprintf("rollup %s of %s", r.interval, name)
+		if r.interval <= 0 {
+			c.report(page, "root collection", "%s has a rollup with interval %s", name, r.interval)
+		}
+		if intervals[int64(r.interval)] {
+			c.report(page, "root collection", "%s has two rollups with interval %s", name, r.interval)
+		}
+		intervals[int64(r.interval)] = true
+		c.checkTree(r.tree.rootNodePage, tree, func(pgnum, *Item) {})
+	}
+
+	names := map[string]bool{}
+	for _, idx := range collection.indexes {
+		tree := fmt.Sprintf("index %s of %s", idx.name, name)
+		if names[string(idx.name)] {
+			c.report(page, "root collection", "%s has two indexes named %s", name, idx.name)
+		}
+		names[string(idx.name)] = true
+		c.checkTree(idx.tree.rootNodePage, tree, func(pgnum, *Item) {})
+	}
+}
+
+// checkFreelist checks that every page is either reachable or free, and only one of them
+func (c *checker) checkFreelist() {
+	freelist := c.tx.db.freelist
+	free := map[pgnum]bool{}
+	for _, page := range freelist.scrapedPages {
+		switch {
+		case page == metaPageNum || page == c.tx.db.freelistPage:
+			c.report(page, "freelist", "meta or freelist page is marked free")
+		case page > freelist.maxAllowedPage:
+			c.report(page, "freelist", "free page is past the end of the file")
+		case free[page]:
+			c.report(page, "freelist", "page is marked free twice")
+		case c.owners[page] != "":
+			c.report(page, "freelist", "free page is used by %s", c.owners[page])
+		}
+		free[page] = true
+	}
+
+	for page := pgnum(1); page <= freelist.maxAllowedPage; page++ {
+		if page == c.tx.db.freelistPage {
+			continue
+		}
+		if c.owners[page] == "" && !free[page] {
+			c.report(page, "", "page is neither reachable nor free")
+		}
+	}
+}
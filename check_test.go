@@ -0,0 +1,194 @@
+package customdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// putTestTreeItems puts count items of the size in a new collection, numbered like the items of testTree, and returns
+// their keys
+func putTestTreeItems(t *testing.T, db *DB, count int, size int) [][]byte {
+	t.Helper()
+	keys := make([][]byte, 0, count)
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.CreateCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			item := testTreeItem(i, size)
+			err = collection.Put(item.Key, item.Value)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, item.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// firstTestLeaf returns the page of the left-most leaf of the test collection and the number of its items
+func firstTestLeaf(t *testing.T, db *DB) (pgnum, int) {
+	t.Helper()
+	tx := db.ReadTx()
+	defer tx.Rollback()
+
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := tx.getNode(collection.rootNodePage)
+	for err == nil && !node.isLeaf() {
+		node, err = tx.getNode(node.childNodes[0])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node.pageNum, len(node.items)
+}
+
+// damageTestPage closes the database and overwrites the page with bytes no page starts with
+func damageTestPage(t *testing.T, db *DB, path string, page pgnum) {
+	t.Helper()
+	pSize := db.pSize
+	err := db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	_, err = file.WriteAt(memset([]byte{0xff}, pSize), int64(page)*int64(pSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectTestViolation fails the test unless Check finds a violation on the page with the problem
+func expectTestViolation(t *testing.T, db *DB, page pgnum, problem string) {
+	t.Helper()
+	violations, err := db.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range violations {
+		if v.Page == uint64(page) && strings.Contains(v.Problem, problem) {
+			return
+		}
+	}
+	t.Fatalf("expected a violation %q on page %d, got %v", problem, page, violations)
+}
+
+func TestDB_Check(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	keys := putTestTreeItems(t, db, 200, 100)
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex([]byte("first-byte"), func(key []byte, value []byte) []byte {
+			return key[:1]
+		})
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(keys); i += 3 {
+			err = collection.Remove(keys[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+}
+
+func TestDB_CheckKeyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(tx *Tx) *node
+		problem string
+	}{
+		{
+			name: "node",
+			build: func(tx *Tx) *node {
+				return tx.createNode(tx.newNode(createItems("2", "1"), nil))
+			},
+			problem: "isn't greater than the key before it",
+		},
+		{
+			name: "parent",
+			build: func(tx *Tx) *node {
+				left := tx.createNode(tx.newNode(createItems("1"), nil))
+				right := tx.createNode(tx.newNode(createItems("2"), nil))
+				return tx.createNode(tx.newNode(createItems("3"), []pgnum{left.pageNum, right.pageNum}))
+			},
+			problem: "isn't greater than the key",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+			defer db.Close()
+
+			var root *node
+			err := db.Update(func(tx *Tx) error {
+				root = test.build(tx)
+				_, err := tx.createCollection(newCollection(testCollectionName, root.pageNum))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page := root.pageNum
+			if !root.isLeaf() {
+				page = root.childNodes[1]
+			}
+			expectTestViolation(t, db, page, test.problem)
+		})
+	}
+}
+
+func TestDB_CheckUnreachablePage(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	var page pgnum
+	err := db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		page = tx.createNode(tx.newNode(createItems("1"), nil)).pageNum
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTestViolation(t, db, page, "neither reachable nor free")
+}
+
+func TestDB_CheckDamagedPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	putTestTreeItems(t, db, 20, bigTestItem)
+	page, _ := firstTestLeaf(t, db)
+	damageTestPage(t, db, path, page)
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	expectTestViolation(t, db, page, "can't read node")
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+const checkUsage = "check [-min fill] [-max fill] <file>"
+
+// runCheck reports every inconsistency of the file, it fails if there is any
+func runCheck(args []string) error {
+	flags := newFlagSet("check", checkUsage)
+	minStored := flags.Float64("min", defaultMinStored, "lower fill bound the file was written with")
+	maxStored := flags.Float64("max", defaultMaxStored, "upper fill bound the file was written with")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	path, err := fileArg(flags)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(path, true, *minStored, *maxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	violations, err := db.Check()
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	if len(violations) > 0 {
+		fmt.Printf("violations found: %d\n", len(violations))
+		return errFailed
+	}
+	fmt.Println("no violations found")
+	return nil
+}
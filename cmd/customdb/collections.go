@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const (
+	collectionsUsage      = "collections <file>"
+	createCollectionUsage = "create-collection [-time-series] <file> <collection>"
+	dropCollectionUsage   = "drop-collection <file> <collection>"
+)
+
+// runCollections prints the names of the collections
+func runCollections(args []string) error {
+	flags := newFlagSet("collections", collectionsUsage)
+	err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *customdb.Tx) error {
+		names, err := tx.Collections()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(string(name))
+		}
+		return nil
+	})
+}
+
+// runCreateCollection creates an empty collection, and the file if it doesn't exist
+func runCreateCollection(args []string) error {
+	flags := newFlagSet("create-collection", createCollectionUsage)
+	timeSeries := flags.Bool("time-series", false, "create a collection for keys that only grow")
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	db, err := createDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *customdb.Tx) error {
+		if *timeSeries {
+			_, err = tx.CreateTimeSeriesCollection([]byte(flags.Arg(1)))
+		} else {
+			_, err = tx.CreateCollection([]byte(flags.Arg(1)))
+		}
+		return err
+	})
+}
+
+// runDropCollection deletes a collection with its items
+func runDropCollection(args []string) error {
+	flags := newFlagSet("drop-collection", dropCollectionUsage)
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *customdb.Tx) error {
+		return tx.DeleteCollection([]byte(flags.Arg(1)))
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const importCSVUsage = "import-csv [-key template] [-value column] [-comma c] [-batch size] <file> <collection> [input]"
+
+// runImportCSV puts the rows of a CSV file in a collection, creating the file and the collection if they don't exist
+func runImportCSV(args []string) error {
+	flags := newFlagSet("import-csv", importCSVUsage)
+	key := flags.String("key", "", "column of the keys, or a template of columns like {ts}:{name}, the first column if not set")
+	value := flags.String("value", "", "column of the values, the whole row as a JSON object if not set")
+	comma := flags.String("comma", ",", "field delimiter")
+	batch := flags.Int("batch", 1000, "number of rows written in a transaction")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 2 || flags.NArg() > 3 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	delimiter, size := utf8.DecodeRuneInString(*comma)
+	if size == 0 || size != len(*comma) {
+		return errors.New("the delimiter must be a single character")
+	}
+
+	var r io.Reader = os.Stdin
+	if flags.NArg() == 3 {
+		f, err := os.Open(flags.Arg(2))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	db, err := createDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	count, err := db.ImportCSV(bufio.NewReader(r), []byte(flags.Arg(1)), &customdb.CSVOptions{
+		Key:       *key,
+		Value:     *value,
+		Comma:     delimiter,
+		BatchSize: *batch,
+	})
+	fmt.Fprintf(os.Stderr, "%d rows imported\n", count)
+	return writeErr(err)
+}
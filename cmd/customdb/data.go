@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const (
+	getUsage  = "get [-key-encoding enc] [-value-encoding enc] <file> <collection> <key>"
+	putUsage  = "put [-key-encoding enc] [-value-encoding enc] <file> <collection> <key> <value>"
+	delUsage  = "del [-key-encoding enc] <file> <collection> <key>"
+	scanUsage = "scan [-key-encoding enc] [-value-encoding enc] [-prefix key] [-limit n] <file> <collection>"
+)
+
+var errKeyNotFound = errors.New("key not found")
+
+// writeErr explains why the CLI can't write to an indexed collection: the extractors of its indexes are code of the
+// program that created them, so the CLI has no way to keep the index entries in sync
+func writeErr(err error) error {
+	if errors.Is(err, customdb.ErrIndexNotRegistered) {
+		return fmt.Errorf("%w; the collection has indexes, write to it from the program that created them", err)
+	}
+	return err
+}
+
+// parseArgs parses the flags of a command and checks the number of arguments after them
+func parseArgs(flags *flag.FlagSet, args []string, count int) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != count {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+// runGet prints the value of a key
+func runGet(args []string) error {
+	flags := newFlagSet("get", getUsage)
+	enc := encodingFlags(flags)
+	err := parseArgs(flags, args, 3)
+	if err != nil {
+		return err
+	}
+	err = enc.check()
+	if err != nil {
+		return err
+	}
+	key, err := enc.decodeKey(flags.Arg(2))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		item, err := collection.Find(key)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return errKeyNotFound
+		}
+		fmt.Println(enc.encodeValue(item.Value))
+		return nil
+	})
+}
+
+// runPut sets the value of a key, replacing the old one
+func runPut(args []string) error {
+	flags := newFlagSet("put", putUsage)
+	enc := encodingFlags(flags)
+	err := parseArgs(flags, args, 4)
+	if err != nil {
+		return err
+	}
+	err = enc.check()
+	if err != nil {
+		return err
+	}
+	key, err := enc.decodeKey(flags.Arg(2))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	value, err := enc.decodeValue(flags.Arg(3))
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+
+	db, err := openDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		return collection.Put(key, value)
+	})
+	return writeErr(err)
+}
+
+// runDel removes a key, failing if it doesn't exist
+func runDel(args []string) error {
+	flags := newFlagSet("del", delUsage)
+	enc := encodingFlags(flags)
+	err := parseArgs(flags, args, 3)
+	if err != nil {
+		return err
+	}
+	err = enc.check()
+	if err != nil {
+		return err
+	}
+	key, err := enc.decodeKey(flags.Arg(2))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	db, err := openDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		item, err := collection.Find(key)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return errKeyNotFound
+		}
+		return collection.Remove(key)
+	})
+	return writeErr(err)
+}
+
+// runScan prints the items of a collection in key order, one "key<TAB>value" line each
+func runScan(args []string) error {
+	flags := newFlagSet("scan", scanUsage)
+	enc := encodingFlags(flags)
+	prefixArg := flags.String("prefix", "", "only print keys starting with prefix, in the key encoding")
+	limit := flags.Int("limit", 0, "stop after n items, 0 prints all of them")
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	err = enc.check()
+	if err != nil {
+		return err
+	}
+	prefix, err := enc.decodeKey(*prefixArg)
+	if err != nil {
+		return fmt.Errorf("invalid prefix: %w", err)
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		cursor := collection.Cursor()
+		printed := 0
+		item, err := cursor.Seek(prefix)
+		for ; err == nil && item != nil && bytes.HasPrefix(item.Key, prefix); item, err = cursor.Next() {
+			if *limit > 0 && printed == *limit {
+				break
+			}
+			fmt.Printf("%s\t%s\n", enc.encodeKey(item.Key), enc.encodeValue(item.Value))
+			printed++
+		}
+		return err
+	})
+}
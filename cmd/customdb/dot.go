@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const dotUsage = "dot [-o output] <file> <collection>"
+
+// runDot writes the tree of a collection as a Graphviz graph, to render with dot -Tsvg
+func runDot(args []string) error {
+	flags := newFlagSet("dot", dotUsage)
+	output := flags.String("o", "", "write the graph to a file instead of the standard output")
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.View(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		return collection.WriteDOT(w)
+	})
+	if w != os.Stdout {
+		closeErr := w.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+)
+
+const (
+	encodingUTF8   = "utf8"
+	encodingHex    = "hex"
+	encodingBase64 = "base64"
+)
+
+// encodings are the encodings of the keys and values in the arguments and output of a command
+type encodings struct {
+	key   *string
+	value *string
+}
+
+func encodingFlags(flags *flag.FlagSet) encodings {
+	return encodings{
+		key:   flags.String("key-encoding", encodingUTF8, "encoding of keys: utf8, hex or base64"),
+		value: flags.String("value-encoding", encodingUTF8, "encoding of values: utf8, hex or base64"),
+	}
+}
+
+// check fails for an unknown encoding before anything is read or written
+func (e encodings) check() error {
+	for _, encoding := range []string{*e.key, *e.value} {
+		switch encoding {
+		case encodingUTF8, encodingHex, encodingBase64:
+		default:
+			return fmt.Errorf("unknown encoding %q", encoding)
+		}
+	}
+	return nil
+}
+
+func (e encodings) decodeKey(s string) ([]byte, error) {
+	return decode(*e.key, s)
+}
+
+func (e encodings) decodeValue(s string) ([]byte, error) {
+	return decode(*e.value, s)
+}
+
+func (e encodings) encodeKey(b []byte) string {
+	return encode(*e.key, b)
+}
+
+func (e encodings) encodeValue(b []byte) string {
+	return encode(*e.value, b)
+}
+
+func decode(encoding string, s string) ([]byte, error) {
+	switch encoding {
+	case encodingHex:
+		return hex.DecodeString(s)
+	case encodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return []byte(s), nil
+	}
+}
+
+func encode(encoding string, b []byte) string {
+	switch encoding {
+	case encodingHex:
+		return hex.EncodeToString(b)
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return string(b)
+	}
+}
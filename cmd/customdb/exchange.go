@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exportUsage = "export [-o output] <file> [collection...]"
+	importUsage = "import [-batch size] <file> [input]"
+)
+
+// runExport writes the items of a database as JSON Lines, to move them to another database or to diff them
+func runExport(args []string) error {
+	flags := newFlagSet("export", exportUsage)
+	output := flags.String("o", "", "write the items to a file instead of the standard output")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	collections := make([][]byte, 0, flags.NArg()-1)
+	for _, name := range flags.Args()[1:] {
+		collections = append(collections, []byte(name))
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.Export(w, collections...)
+	if w != os.Stdout {
+		closeErr := w.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// runImport puts the items written by export in a database, creating the file and the collections it misses
+func runImport(args []string) error {
+	flags := newFlagSet("import", importUsage)
+	batch := flags.Int("batch", 1000, "number of items written in a transaction")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	var r io.Reader = os.Stdin
+	if flags.NArg() == 2 {
+		f, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	db, err := createDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	count, err := db.Import(bufio.NewReader(r), *batch)
+	fmt.Fprintf(os.Stderr, "%d items imported\n", count)
+	return writeErr(err)
+}
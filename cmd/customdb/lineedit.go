@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyTab       = 9
+	keyEnter     = 13
+	keyNewline   = 10
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// maxHistory is the number of lines kept in the history file
+const maxHistory = 1000
+
+// errInterrupted is returned by readLine when the line is dropped with Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the lines of the shell. On a terminal it edits them in raw mode with a history and completion,
+// otherwise it reads plain lines.
+type lineReader struct {
+	in       *os.File
+	reader   *bufio.Reader
+	out      io.Writer
+	terminal bool
+
+	history     []string
+	historyPath string
+	// complete returns the words that can replace the last word of the line before the cursor
+	complete func(line string) []string
+}
+
+func newLineReader(in *os.File, out io.Writer, historyPath string, complete func(line string) []string) *lineReader {
+	r := &lineReader{
+		in:          in,
+		reader:      bufio.NewReader(in),
+		out:         out,
+		terminal:    isTerminal(in.Fd()),
+		historyPath: historyPath,
+		complete:    complete,
+	}
+	r.loadHistory()
+	return r
+}
+
+func (r *lineReader) loadHistory() {
+	if r.historyPath == "" {
+		return
+	}
+	content, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+// saveHistory writes the history back, a shell that can't do it still works
+func (r *lineReader) saveHistory() {
+	if r.historyPath == "" || len(r.history) == 0 {
+		return
+	}
+	_ = os.WriteFile(r.historyPath, []byte(strings.Join(r.history, "\n")+"\n"), 0600)
+}
+
+func (r *lineReader) addHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if len(r.history) > 0 && r.history[len(r.history)-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if len(r.history) > maxHistory {
+		r.history = r.history[1:]
+	}
+}
+
+// readLine prints the prompt and returns the line typed, io.EOF at the end of the input or on Ctrl-D on an empty line
+func (r *lineReader) readLine(prompt string) (string, error) {
+	if !r.terminal {
+		return r.readPlainLine(prompt)
+	}
+
+	restore, err := makeRaw(r.in.Fd())
+	if err != nil {
+		return r.readPlainLine(prompt)
+	}
+	defer restore()
+
+	e := &lineEditor{r: r, prompt: prompt, historyIndex: len(r.history)}
+	line, err := e.edit()
+	// raw mode doesn't turn the new line into a carriage return
+	fmt.Fprint(r.out, "\r\n")
+	if err == nil {
+		r.addHistory(line)
+	}
+	return line, err
+}
+
+func (r *lineReader) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	r.addHistory(line)
+	return line, nil
+}
+
+// lineEditor is the state of the line being typed on a terminal
+type lineEditor struct {
+	r      *lineReader
+	prompt string
+	line   []rune
+	cursor int
+	// historyIndex is the history line shown, len(history) for the line being typed, which is kept in pending
+	historyIndex int
+	pending      []rune
+}
+
+func (e *lineEditor) edit() (string, error) {
+	e.redraw()
+	for {
+		key, _, err := e.r.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, keyNewline:
+			return string(e.line), nil
+		case keyCtrlC:
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				return "", io.EOF
+			}
+			e.deleteForward()
+		case keyBackspace, keyCtrlH:
+			if e.cursor > 0 {
+				e.line = append(e.line[:e.cursor-1], e.line[e.cursor:]...)
+				e.cursor--
+			}
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlU:
+			e.line = e.line[e.cursor:]
+			e.cursor = 0
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			err = e.escape()
+			if err != nil {
+				return "", err
+			}
+		default:
+			if key >= ' ' {
+				e.line = append(e.line[:e.cursor], append([]rune{key}, e.line[e.cursor:]...)...)
+				e.cursor++
+			}
+		}
+		e.redraw()
+	}
+}
+
+// escape handles the sequences of the arrow, home, end and delete keys
+func (e *lineEditor) escape() error {
+	prefix, err := e.r.reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if prefix != '[' && prefix != 'O' {
+		return nil
+	}
+	code, err := e.r.reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 'A':
+		e.showHistory(e.historyIndex - 1)
+	case 'B':
+		e.showHistory(e.historyIndex + 1)
+	case 'C':
+		if e.cursor < len(e.line) {
+			e.cursor++
+		}
+	case 'D':
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case 'H':
+		e.cursor = 0
+	case 'F':
+		e.cursor = len(e.line)
+	case '3':
+		// delete is ESC [ 3 ~
+		_, err = e.r.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		e.deleteForward()
+	}
+	return nil
+}
+
+func (e *lineEditor) deleteForward() {
+	if e.cursor < len(e.line) {
+		e.line = append(e.line[:e.cursor], e.line[e.cursor+1:]...)
+	}
+}
+
+func (e *lineEditor) showHistory(index int) {
+	history := e.r.history
+	if index < 0 || index > len(history) {
+		return
+	}
+	if e.historyIndex == len(history) {
+		e.pending = e.line
+	}
+	e.historyIndex = index
+	if index == len(history) {
+		e.line = e.pending
+	} else {
+		e.line = []rune(history[index])
+	}
+	e.cursor = len(e.line)
+}
+
+// completeWord completes the word before the cursor. A single candidate replaces the word, several ones extend it to
+// their common prefix or are listed below the line.
+func (e *lineEditor) completeWord() {
+	if e.r.complete == nil {
+		return
+	}
+	before := string(e.line[:e.cursor])
+	candidates := e.r.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := before[strings.LastIndex(before, " ")+1:]
+	replacement := candidates[0] + " "
+	if len(candidates) > 1 {
+		replacement = commonPrefix(candidates)
+		if len(replacement) <= len(word) {
+			fmt.Fprintf(e.r.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			return
+		}
+	}
+
+	start := e.cursor - len([]rune(word))
+	rest := append([]rune(replacement), e.line[e.cursor:]...)
+	e.line = append(e.line[:start], rest...)
+	e.cursor = start + len([]rune(replacement))
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// redraw prints the prompt and the line again and puts the cursor back in place
+func (e *lineEditor) redraw() {
+	fmt.Fprintf(e.r.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.r.out, "\x1b[%dD", back)
+	}
+}
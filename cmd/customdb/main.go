@@ -0,0 +1,123 @@
+// Command customdb inspects and repairs database files.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+// command is a subcommand of the tool, run with the arguments after its name
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"check":             {checkUsage, runCheck},
+	"recover":           {recoverUsage, runRecover},
+	"get":               {getUsage, runGet},
+	"put":               {putUsage, runPut},
+	"del":               {delUsage, runDel},
+	"scan":              {scanUsage, runScan},
+	"collections":       {collectionsUsage, runCollections},
+	"create-collection": {createCollectionUsage, runCreateCollection},
+	"drop-collection":   {dropCollectionUsage, runDropCollection},
+	"shell":             {shellUsage, runShell},
+	"pages":             {pagesUsage, runPages},
+	"page":              {pageUsage, runPage},
+	"dot":               {dotUsage, runDot},
+	"export":            {exportUsage, runExport},
+	"import":            {importUsage, runImport},
+	"import-csv":        {importCSVUsage, runImportCSV},
+}
+
+// the fill bounds of customdb.DefaultParams, as flag defaults that print without float32 rounding
+const (
+	defaultMinStored = 0.5
+	defaultMaxStored = 0.95
+)
+
+// errFailed makes the tool exit with 1 after the command already reported why
+var errFailed = errors.New("failed")
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "customdb: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(flag.Args()[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		if !errors.Is(err, errFailed) {
+			fmt.Fprintf(os.Stderr, "customdb: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: customdb <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  customdb %s\n", commands[name].usage)
+	}
+}
+
+// newFlagSet returns the flags of a command, which print the usage of the command on errors
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: customdb %s\n", usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// fileArg returns the single file argument of a command
+func fileArg(flags *flag.FlagSet) (string, error) {
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", flag.ErrHelp
+	}
+	return flags.Arg(0), nil
+}
+
+// openDB opens an existing database file, with the fill bounds given to the command
+func openDB(path string, readOnly bool, minStored float64, maxStored float64) (*customdb.DB, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return createDB(path, readOnly, minStored, maxStored)
+}
+
+// createDB opens a database file like openDB, creating it if it doesn't exist
+func createDB(path string, readOnly bool, minStored float64, maxStored float64) (*customdb.DB, error) {
+	params := *customdb.DefaultParams
+	params.MinStored = float32(minStored)
+	params.MaxStored = float32(maxStored)
+	params.ReadOnly = readOnly
+	return customdb.Open(path, &params)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const (
+	pagesUsage = "pages <file>"
+	pageUsage  = "page <file> <page number>"
+)
+
+// hexRowSize is the number of bytes in a row of the hex dump
+const hexRowSize = 16
+
+// runPages lists every page of the file with its type and the tree it belongs to
+func runPages(args []string) error {
+	flags := newFlagSet("pages", pagesUsage)
+	err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	pages, err := db.Pages()
+	if err != nil {
+		return err
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PAGE\tTYPE\tITEMS\tFILL\tTREE")
+	for _, page := range pages {
+		items, fill := "", ""
+		if page.Type == customdb.PageBranch || page.Type == customdb.PageLeaf {
+			items = strconv.Itoa(page.Items)
+			fill = fmt.Sprintf("%.1f%%", float64(page.Size)/float64(stats.PageSize)*100)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", page.Num, page.Type, items, fill, page.Tree)
+	}
+	return w.Flush()
+}
+
+// runPage prints the fields of a page followed by its raw bytes
+func runPage(args []string) error {
+	flags := newFlagSet("page", pageUsage)
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	num, err := strconv.ParseUint(flags.Arg(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid page number %q", flags.Arg(1))
+	}
+
+	db, err := openDB(flags.Arg(0), true, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dump, err := db.Page(num)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("page %d: %s\n", dump.Num, dump.Type)
+	if dump.Tree != "" {
+		fmt.Printf("tree: %s\n", dump.Tree)
+	}
+	switch dump.Type {
+	case customdb.PageMeta:
+		fmt.Printf("root page: %d\nfreelist page: %d\n", dump.Root, dump.FreelistPage)
+	case customdb.PageFreelist:
+		fmt.Printf("last page: %d\nfree pages: %v\n", dump.LastPage, dump.FreePages)
+	case customdb.PageBranch, customdb.PageLeaf:
+		printNode(dump)
+	case customdb.PageUnreadable:
+		fmt.Printf("problem: %s\n", dump.Problem)
+	}
+
+	if dump.Data != nil {
+		fmt.Println()
+		hexDump(os.Stdout, dump.Data)
+	}
+	return nil
+}
+
+func printNode(dump *customdb.PageDump) {
+	fmt.Printf("items: %d, %d bytes\n", len(dump.Items), dump.Size)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, item := range dump.Items {
+		if i < len(dump.Children) && dump.Type == customdb.PageBranch {
+			fmt.Fprintf(w, "\t-> page %d\t\n", dump.Children[i])
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i, printable(item.Key), printable(item.Value))
+	}
+	if dump.Type == customdb.PageBranch && len(dump.Children) > 0 {
+		fmt.Fprintf(w, "\t-> page %d\t\n", dump.Children[len(dump.Children)-1])
+	}
+	_ = w.Flush()
+}
+
+// hexDump prints data like hexdump -C, with a * for rows repeating the one above
+func hexDump(w io.Writer, data []byte) {
+	var previous []byte
+	repeating := false
+	for offset := 0; offset < len(data); offset += hexRowSize {
+		end := offset + hexRowSize
+		if end > len(data) {
+			end = len(data)
+		}
+		row := data[offset:end]
+		if previous != nil && bytes.Equal(row, previous) {
+			if !repeating {
+				fmt.Fprintln(w, "*")
+				repeating = true
+			}
+			continue
+		}
+		previous, repeating = row, false
+
+		fmt.Fprintf(w, "%08x  ", offset)
+		for i := 0; i < hexRowSize; i++ {
+			if i < len(row) {
+				fmt.Fprintf(w, "%02x ", row[i])
+			} else {
+				fmt.Fprint(w, "   ")
+			}
+			if i == hexRowSize/2-1 {
+				fmt.Fprint(w, " ")
+			}
+		}
+		fmt.Fprint(w, " |")
+		for _, b := range row {
+			if b < ' ' || b > '~' {
+				b = '.'
+			}
+			fmt.Fprintf(w, "%c", b)
+		}
+		fmt.Fprintln(w, "|")
+	}
+	fmt.Fprintf(w, "%08x\n", len(data))
+}
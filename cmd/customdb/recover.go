@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const recoverUsage = "recover [-min fill] [-max fill] <damaged file> <new file>"
+
+// runRecover salvages the items of a damaged file into a new one and reports what was lost
+func runRecover(args []string) error {
+	flags := newFlagSet("recover", recoverUsage)
+	minStored := flags.Float64("min", defaultMinStored, "lower fill bound of the new file")
+	maxStored := flags.Float64("max", defaultMaxStored, "upper fill bound of the new file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	params := *customdb.DefaultParams
+	params.MinStored = float32(*minStored)
+	params.MaxStored = float32(*maxStored)
+	report, err := customdb.Recover(flags.Arg(0), flags.Arg(1), &params)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("pages scanned: %d\n", report.Pages)
+	if report.MetaDamaged {
+		fmt.Println("meta page is damaged, collections are unknown")
+	}
+	if report.FreelistDamaged {
+		fmt.Println("freelist is damaged, deleted items may have come back")
+	}
+	for _, collection := range report.Collections {
+		fmt.Printf("collection %s: %d items recovered, %d pages lost\n", collection.Name, collection.Items,
+			collection.UnreadablePages)
+	}
+	if report.LostAndFoundItems > 0 {
+		fmt.Printf("collection %s: %d items of unknown collections\n", customdb.LostAndFound, report.LostAndFoundItems)
+	}
+	for _, page := range report.UnreadablePages {
+		fmt.Printf("page %d: unreadable, its items are lost\n", page)
+	}
+	for _, index := range report.LostIndexes {
+		fmt.Printf("index %s: not recovered, create it again\n", index)
+	}
+	return nil
+}
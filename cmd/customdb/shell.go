@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const shellUsage = "shell <file>"
+
+const shellHelp = `commands:
+  collections                       list the collections
+  create <collection>               create a collection
+  create-time-series <collection>   create a collection for keys that only grow
+  drop <collection>                 delete a collection with its items
+  get <collection> <key>            print the value of a key
+  put <collection> <key> <value>    set the value of a key
+  del <collection> <key>            remove a key
+  scan <collection> [prefix] [limit]
+                                    print the items of a collection in key order
+  encoding [key|value] [utf8|hex|base64]
+                                    show or set how keys and values are typed and printed
+  begin                             start a write transaction, the commands after it run in it
+  commit                            commit the transaction started by begin
+  rollback                          roll back the transaction started by begin
+  help                              print this help
+  exit                              leave the shell, rolling back an open transaction
+Arguments with spaces go in single or double quotes.`
+
+// shellCommands are completed on the first word, the ones taking a collection first have it completed after them
+var shellCommands = map[string]bool{
+	"collections":        false,
+	"create":             false,
+	"create-time-series": false,
+	"drop":               true,
+	"get":                true,
+	"put":                true,
+	"del":                true,
+	"scan":               true,
+	"encoding":           false,
+	"begin":              false,
+	"commit":             false,
+	"rollback":           false,
+	"help":               false,
+	"exit":               false,
+}
+
+var errShellExit = errors.New("exit")
+
+// shell runs the commands of an interactive session on an open database
+type shell struct {
+	db  *customdb.DB
+	out io.Writer
+	// tx is the transaction started by begin, commands run in their own transaction without it
+	tx            *customdb.Tx
+	keyEncoding   string
+	valueEncoding string
+}
+
+// runShell starts an interactive session on a database file
+func runShell(args []string) error {
+	flags := newFlagSet("shell", shellUsage)
+	err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.Arg(0), false, defaultMinStored, defaultMaxStored)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sh := &shell{
+		db:            db,
+		out:           os.Stdout,
+		keyEncoding:   encodingUTF8,
+		valueEncoding: encodingUTF8,
+	}
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, ".customdb_history")
+	}
+	reader := newLineReader(os.Stdin, os.Stdout, historyPath, sh.complete)
+	defer reader.saveHistory()
+	if reader.terminal {
+		fmt.Fprintln(sh.out, `type "help" for the list of commands`)
+	}
+
+	for {
+		line, err := reader.readLine(sh.prompt())
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = sh.exec(line)
+		if errors.Is(err, errShellExit) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+		}
+	}
+
+	if sh.tx != nil {
+		fmt.Fprintln(sh.out, "rolling back the open transaction")
+		return sh.tx.Rollback()
+	}
+	return nil
+}
+
+func (sh *shell) prompt() string {
+	if sh.tx != nil {
+		return "customdb (tx)> "
+	}
+	return "customdb> "
+}
+
+// exec runs a line typed in the shell
+func (sh *shell) exec(line string) error {
+	args, err := splitLine(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	name, args := args[0], args[1:]
+	switch name {
+	case "help":
+		fmt.Fprintln(sh.out, shellHelp)
+		return nil
+	case "exit", "quit":
+		return errShellExit
+	case "begin":
+		return sh.begin(args)
+	case "commit":
+		return sh.finish(args, (*customdb.Tx).Commit)
+	case "rollback":
+		return sh.finish(args, (*customdb.Tx).Rollback)
+	case "encoding":
+		return sh.encoding(args)
+	case "collections":
+		return sh.collections(args)
+	case "create", "create-time-series":
+		return sh.create(args, name == "create-time-series")
+	case "drop":
+		return sh.drop(args)
+	case "get":
+		return sh.get(args)
+	case "put":
+		return sh.put(args)
+	case "del":
+		return sh.del(args)
+	case "scan":
+		return sh.scan(args)
+	default:
+		return fmt.Errorf("unknown command %q, try help", name)
+	}
+}
+
+// splitLine splits a line in words at spaces outside quotes. A backslash keeps the next character as is outside
+// single quotes.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func expectArgs(args []string, min int, max int, usage string) error {
+	if len(args) < min || len(args) > max {
+		return fmt.Errorf("usage: %s", usage)
+	}
+	return nil
+}
+
+// view runs fn in the transaction started by begin, or in a read transaction of its own
+func (sh *shell) view(fn func(tx *customdb.Tx) error) error {
+	if sh.tx != nil {
+		return fn(sh.tx)
+	}
+	return sh.db.View(fn)
+}
+
+// update runs fn in the transaction started by begin, or in a write transaction of its own
+func (sh *shell) update(fn func(tx *customdb.Tx) error) error {
+	if sh.tx != nil {
+		return fn(sh.tx)
+	}
+	return sh.db.Update(fn)
+}
+
+func (sh *shell) begin(args []string) error {
+	err := expectArgs(args, 0, 0, "begin")
+	if err != nil {
+		return err
+	}
+	if sh.tx != nil {
+		return errors.New("a transaction is already open, commit or roll it back first")
+	}
+	sh.tx = sh.db.WriteTx()
+	return nil
+}
+
+func (sh *shell) finish(args []string, end func(*customdb.Tx) error) error {
+	err := expectArgs(args, 0, 0, "commit or rollback")
+	if err != nil {
+		return err
+	}
+	if sh.tx == nil {
+		return errors.New("no transaction is open, start one with begin")
+	}
+	tx := sh.tx
+	sh.tx = nil
+	return end(tx)
+}
+
+func (sh *shell) encoding(args []string) error {
+	err := expectArgs(args, 0, 2, "encoding [key|value] [utf8|hex|base64]")
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		fmt.Fprintf(sh.out, "key: %s\nvalue: %s\n", sh.keyEncoding, sh.valueEncoding)
+		return nil
+	}
+
+	encoding := args[1]
+	err = encodings{key: &encoding, value: &encoding}.check()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "key":
+		sh.keyEncoding = encoding
+	case "value":
+		sh.valueEncoding = encoding
+	default:
+		return fmt.Errorf("unknown encoding target %q, use key or value", args[0])
+	}
+	return nil
+}
+
+func (sh *shell) collections(args []string) error {
+	err := expectArgs(args, 0, 0, "collections")
+	if err != nil {
+		return err
+	}
+	return sh.view(func(tx *customdb.Tx) error {
+		names, err := tx.Collections()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(sh.out, string(name))
+		}
+		fmt.Fprintf(sh.out, "(%d collections)\n", len(names))
+		return nil
+	})
+}
+
+func (sh *shell) create(args []string, timeSeries bool) error {
+	err := expectArgs(args, 1, 1, "create <collection>")
+	if err != nil {
+		return err
+	}
+	return sh.update(func(tx *customdb.Tx) error {
+		if timeSeries {
+			_, err = tx.CreateTimeSeriesCollection([]byte(args[0]))
+		} else {
+			_, err = tx.CreateCollection([]byte(args[0]))
+		}
+		return err
+	})
+}
+
+func (sh *shell) drop(args []string) error {
+	err := expectArgs(args, 1, 1, "drop <collection>")
+	if err != nil {
+		return err
+	}
+	return sh.update(func(tx *customdb.Tx) error {
+		return tx.DeleteCollection([]byte(args[0]))
+	})
+}
+
+func (sh *shell) get(args []string) error {
+	err := expectArgs(args, 2, 2, "get <collection> <key>")
+	if err != nil {
+		return err
+	}
+	key, err := decode(sh.keyEncoding, args[1])
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	return sh.view(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(args[0]))
+		if err != nil {
+			return err
+		}
+		item, err := collection.Find(key)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return errKeyNotFound
+		}
+		fmt.Fprintln(sh.out, sh.formatValue(item.Value, true))
+		return nil
+	})
+}
+
+func (sh *shell) put(args []string) error {
+	err := expectArgs(args, 3, 3, "put <collection> <key> <value>")
+	if err != nil {
+		return err
+	}
+	key, err := decode(sh.keyEncoding, args[1])
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	value, err := decode(sh.valueEncoding, args[2])
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	err = sh.update(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(args[0]))
+		if err != nil {
+			return err
+		}
+		return collection.Put(key, value)
+	})
+	return writeErr(err)
+}
+
+func (sh *shell) del(args []string) error {
+	err := expectArgs(args, 2, 2, "del <collection> <key>")
+	if err != nil {
+		return err
+	}
+	key, err := decode(sh.keyEncoding, args[1])
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	err = sh.update(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(args[0]))
+		if err != nil {
+			return err
+		}
+		item, err := collection.Find(key)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return errKeyNotFound
+		}
+		return collection.Remove(key)
+	})
+	return writeErr(err)
+}
+
+func (sh *shell) scan(args []string) error {
+	err := expectArgs(args, 1, 3, "scan <collection> [prefix] [limit]")
+	if err != nil {
+		return err
+	}
+	var prefix []byte
+	if len(args) > 1 {
+		prefix, err = decode(sh.keyEncoding, args[1])
+		if err != nil {
+			return fmt.Errorf("invalid prefix: %w", err)
+		}
+	}
+	limit := 0
+	if len(args) > 2 {
+		limit, err = strconv.Atoi(args[2])
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q", args[2])
+		}
+	}
+
+	return sh.view(func(tx *customdb.Tx) error {
+		collection, err := tx.GetCollection([]byte(args[0]))
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+		printed := 0
+		cursor := collection.Cursor()
+		item, err := cursor.Seek(prefix)
+		for ; err == nil && item != nil && bytes.HasPrefix(item.Key, prefix); item, err = cursor.Next() {
+			if limit > 0 && printed == limit {
+				break
+			}
+			fmt.Fprintf(w, "%s\t%s\n", sh.formatKey(item.Key), sh.formatValue(item.Value, false))
+			printed++
+		}
+		if err != nil {
+			return err
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "(%d items)\n", printed)
+		return nil
+	})
+}
+
+func (sh *shell) formatKey(key []byte) string {
+	if sh.keyEncoding != encodingUTF8 {
+		return encode(sh.keyEncoding, key)
+	}
+	return printable(key)
+}
+
+// formatValue prints JSON values indented if indent is set and compact otherwise
+func (sh *shell) formatValue(value []byte, indent bool) string {
+	if sh.valueEncoding != encodingUTF8 {
+		return encode(sh.valueEncoding, value)
+	}
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		var formatted bytes.Buffer
+		if indent {
+			_ = json.Indent(&formatted, trimmed, "", "  ")
+		} else {
+			_ = json.Compact(&formatted, trimmed)
+		}
+		return formatted.String()
+	}
+	return printable(value)
+}
+
+// printable returns b as text, or as 0x and its hex digits if it isn't printable text
+func printable(b []byte) string {
+	if utf8.Valid(b) {
+		isText := true
+		for _, r := range string(b) {
+			if !unicode.IsPrint(r) {
+				isText = false
+				break
+			}
+		}
+		if isText {
+			return string(b)
+		}
+	}
+	return "0x" + hex.EncodeToString(b)
+}
+
+// complete returns the commands or collection names starting with the last word of line
+func (sh *shell) complete(line string) []string {
+	words := strings.Fields(line)
+	if len(words) == 0 || !strings.HasSuffix(line, " ") {
+		if len(words) > 0 {
+			words = words[:len(words)-1]
+		}
+	}
+	word := line[strings.LastIndex(line, " ")+1:]
+
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		for name := range shellCommands {
+			candidates = append(candidates, name)
+		}
+	case len(words) == 1 && shellCommands[words[0]]:
+		_ = sh.view(func(tx *customdb.Tx) error {
+			names, err := tx.Collections()
+			for _, name := range names {
+				candidates = append(candidates, string(name))
+			}
+			return err
+		})
+	case words[0] == "encoding" && len(words) == 1:
+		candidates = []string{"key", "value"}
+	case words[0] == "encoding" && len(words) == 2:
+		candidates = []string{encodingUTF8, encodingHex, encodingBase64}
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+		error    bool
+	}{
+		{name: "empty", line: "", expected: nil},
+		{name: "spaces", line: "  \t ", expected: nil},
+		{name: "words", line: " put  users\tann 1 ", expected: []string{"put", "users", "ann", "1"}},
+		{name: "double quotes", line: `put users "ann lee" 1`, expected: []string{"put", "users", "ann lee", "1"}},
+		{name: "single quotes", line: `put users 'ann lee' 1`, expected: []string{"put", "users", "ann lee", "1"}},
+		{name: "empty quotes", line: `put users "" ''`, expected: []string{"put", "users", "", ""}},
+		{name: "quotes inside a word", line: `a"b c"d`, expected: []string{"ab cd"}},
+		{name: "other quote inside quotes", line: `"it's" '"x"'`, expected: []string{"it's", `"x"`}},
+		{name: "escaped space", line: `ann\ lee`, expected: []string{"ann lee"}},
+		{name: "escaped quote", line: `\"a "b\"c"`, expected: []string{`"a`, `b"c`}},
+		{name: "escaped backslash", line: `a\\b`, expected: []string{`a\b`}},
+		{name: "backslash in single quotes", line: `'a\b' 'c\'`, expected: []string{`a\b`, `c\`}},
+		{name: "unterminated double quote", line: `put "users`, error: true},
+		{name: "unterminated single quote", line: `put 'it\'s'`, error: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			words, err := splitLine(test.line)
+			if test.error {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", words)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(words, test.expected) {
+				t.Fatalf("expected %q, got %q", test.expected, words)
+			}
+		})
+	}
+}
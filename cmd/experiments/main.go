@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	//"fmt"
+	"strconv"
+	"time"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+// items of the experiments are memset to this size, like in the tests of the package
+const testValSize = 255
+
+func generateId(name string) string {
+	curTime := time.Now().UnixNano()
+	return strconv.Itoa(int(curTime)) + ":" + name
+}
+
+func addManyCollections(db *customdb.DB) {
+	nstart := 0
+	nend := 1
+	for i := nstart; i < nend; i++ {
+		tx := db.WriteTx()
+		name := []byte("newCollection" + strconv.Itoa(i))
+		tx.CreateCollection(name)
+		_ = tx.Commit()
+	}
+}
+
+// test function to fill collections, full docs in lastT.Database
+func addNewElementsToCollection(db *customdb.DB, name string) {
+	nstart := 0
+	nend := 3
+	for i := nstart; i < nend; i++ {
+		time.Sleep(1 * time.Second)
+		randomId := []byte(generateId(string(rune(rand.Intn(3) + 97))))
+		key, value := randomId, randomId
+		err := db.Update(func(tx *customdb.Tx) error {
+			collection, err := tx.GetCollection([]byte(name))
+			if err != nil {
+				return err
+			}
+			return collection.Put(key, value)
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// test function to fill a time-series collection with points of a few series
+func addNewPointsToCollection(db *customdb.DB, name string) {
+	tx := db.WriteTx()
+	collection, _ := tx.CreateTimeSeriesCollection([]byte(name))
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		series := []byte(string(rune(rand.Intn(3) + 97)))
+		_ = collection.PutPoint(series, start.Add(time.Duration(i)*time.Second), rand.Float64())
+	}
+	_ = tx.Commit()
+}
+
+func addNewElementToCollection(db *customdb.DB, name string) {
+	tx := db.WriteTx()
+	keyBuf := bytes.Repeat([]byte(name), testValSize)
+	collection, _ := tx.GetCollection([]byte("test1"))
+	collection.Put(keyBuf, keyBuf)
+	tx.Commit()
+}
+
+func findValueByKey(db *customdb.DB, key string) {
+	tx := db.ReadTx()
+	keyBuf := bytes.Repeat([]byte(key), testValSize)
+	collection, _ := tx.GetCollection([]byte("test1"))
+	item, _ := collection.Find([]byte(keyBuf))
+	tx.Commit()
+	fmt.Printf("key : %s, value: %s\n", item.Key, item.Value)
+}
+
+func worker(id int, wg *sync.WaitGroup, path string, info string) {
+	defer wg.Done()
+
+	fmt.Printf("Worker %d started\n", id)
+	db, _ := customdb.Open(path, customdb.DefaultParams)
+	getAllElementsFromCollectionByDocName(db, info)
+	fmt.Printf("Worker %d finished\n", id)
+}
+
+//Пусть мы провели шардирование
+//Иммитируем наличие шардов разными файлами базы данных, в которых содержатся различные коллекции
+//func imitiateShardedIndexes(db *customdb.DB) {
+//	paths :=[4]string{"1.db","2.db","3.db","4.db"}
+//	info :=[4]string{"a","b","c","d"}
+//	var wg sync.WaitGroup
+//	for i := 1; i <= 4; i++ {
+//		wg.Add(1)
+//		go worker(i, &wg,paths[i],info[i])
+//	}
+//
+//	wg.Wait()
+//	fmt.Println("All workers finished")
+//}
+
+// !!!Результат 22.05 Немного накосячил, производительность выросла в 3.2 раза примерно.
+// !!!Смортеть БД с доками. Скрипт для разбиения отработал, но кривовато, дофиксить
+// Дата пока хранится в имени. Перефиксить обратно на индексы
+// Слайсинг-, удалить методы слайсинга
+func generateRandomString() string {
+	chars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	length := 500
+	result := make([]byte, length)
+
+	for i := 0; i < length; i++ {
+		result[i] = chars[rand.Intn(len(chars))]
+	}
+	fmt.Println(string(result))
+	return string(result)
+}
+
+// getAllElementsHelper scans the collection with a cursor and keeps the items of the given doc
+func getAllElementsHelper(c *customdb.Collection, allElements []customdb.Item, key string) []customdb.Item {
+	cursor := c.Cursor()
+	for item, err := cursor.First(); err == nil && item != nil; item, err = cursor.Next() {
+		if bytes.Compare([]byte(strings.Split(string(item.Key), ":")[1]), []byte(key)) == 0 {
+			allElements = append(allElements, *item)
+		}
+	}
+	return allElements
+}
+
+func getAllElementsFromCollectionByDocName(db *customdb.DB, key string) {
+	tx := db.ReadTx()
+	c, _ := tx.GetCollection([]byte("test1"))
+	var allElements []customdb.Item
+	allElements = getAllElementsHelper(c, allElements, key)
+	fmt.Printf("elements : %s \n", allElements)
+	fmt.Println("length : ", len(allElements))
+	tx.Commit()
+}
+
+func getFilteredElementsFromCollectionByDocName() {
+
+}
+
+func addCollection(db *customdb.DB) {
+	name := []byte("test1")
+	err := db.Update(func(tx *customdb.Tx) error {
+		_, err := tx.CreateCollection(name)
+		return err
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func main() {
+	start := time.Now()
+	path := "temporal.Database"
+	db, _ := customdb.Open(path, customdb.DefaultParams)
+	//items := createItemsCustom([]string{"1:a", "2:b", "3:c", "4:d"})
+	//fmt.Printf("%s", items)
+	addCollection(db)
+	//addManyCollections(db)
+	addNewElementsToCollection(db, "test1")
+	//addNewPointsToCollection(db, "points")
+	//findValueByKey(db, "0")
+	//getAllElementsFromCollectionByDocName(db, "a")
+	//addNewElementToCollection(db, "d")
+	//addNewElementToCollection(db, "e")
+
+	//imitiateShardedIndexes(db)
+	_ = db.Close()
+	duration := time.Since(start)
+	fmt.Println(duration)
+}
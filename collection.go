@@ -1,16 +1,32 @@
-package main
+package customdb
 
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+type collectionMode byte
+
+const (
+	collectionModeDefault collectionMode = iota
+	// keys only grow, so the right-most nodes are split at the end instead of in the middle
+	collectionModeTimeSeries
 )
 
 type Collection struct {
 	name         []byte
 	rootNodePage pgnum
 	counter      uint64
+	mode         collectionMode
+	rollups      []*rollup
+	indexes      []*index
+
+	// collection whose header holds the root of this tree, nil for collections stored in the root collection
+	owner *Collection
 
-	tx *tx
+	tx *Tx
 }
 
 func newCollection(name []byte, rootNodePage pgnum) *Collection {
@@ -24,8 +40,12 @@ func newEmptyCollection() *Collection {
 	return &Collection{}
 }
 
+func (c *Collection) Name() []byte {
+	return c.name
+}
+
 func (c *Collection) ID() uint64 {
-	if !c.tx.write {
+	if c.tx.writable() != nil {
 		return 0
 	}
 
@@ -34,31 +54,133 @@ func (c *Collection) ID() uint64 {
 	return id
 }
 
+func (c *Collection) headerSize() int {
+	size := collectionSize + rollupsCountSize + len(c.rollups)*rollupSize
+	size += indexesCountSize
+	for _, idx := range c.indexes {
+		size += idx.headerSize()
+	}
+	return size
+}
+
 func (c *Collection) serialize() *Item {
-	b := make([]byte, collectionSize)
+	b := make([]byte, c.headerSize())
 	leftPos := 0
 	binary.LittleEndian.PutUint64(b[leftPos:], uint64(c.rootNodePage))
 	leftPos += pageNumSize
 	binary.LittleEndian.PutUint64(b[leftPos:], c.counter)
 	leftPos += counterSize
+	b[leftPos] = byte(c.mode)
+	leftPos += 1
+
+	b[leftPos] = byte(len(c.rollups))
+	leftPos += rollupsCountSize
+	for _, r := range c.rollups {
+		binary.LittleEndian.PutUint64(b[leftPos:], uint64(r.interval))
+		leftPos += rollupIntervalSize
+		binary.LittleEndian.PutUint64(b[leftPos:], uint64(r.tree.rootNodePage))
+		leftPos += pageNumSize
+	}
+
+	b[leftPos] = byte(len(c.indexes))
+	leftPos += indexesCountSize
+	for _, idx := range c.indexes {
+		b[leftPos] = byte(len(idx.name))
+		leftPos += 1
+		copy(b[leftPos:], idx.name)
+		leftPos += len(idx.name)
+		b[leftPos] = idx.flags()
+		leftPos += indexFlagsSize
+		binary.LittleEndian.PutUint64(b[leftPos:], uint64(idx.tree.rootNodePage))
+		leftPos += pageNumSize
+	}
 	return newItem(c.name, b)
 }
 
-func (c *Collection) deserialize(item *Item) {
-	c.name = item.key
+func (c *Collection) deserialize(item *Item) error {
+	c.name = item.Key
 
-	if len(item.value) != 0 {
-		leftPos := 0
-		c.rootNodePage = pgnum(binary.LittleEndian.Uint64(item.value[leftPos:]))
-		leftPos += pageNumSize
+	if len(item.Value) == 0 {
+		return nil
+	}
+	if len(item.Value) < pageNumSize+counterSize {
+		return fmt.Errorf("%w: header of collection %q is too small", ErrCorrupted, c.name)
+	}
+
+	leftPos := 0
+	c.rootNodePage = pgnum(binary.LittleEndian.Uint64(item.Value[leftPos:]))
+	leftPos += pageNumSize
+
+	c.counter = binary.LittleEndian.Uint64(item.Value[leftPos:])
+	leftPos += counterSize
+
+	// headers written before collection modes have no mode byte
+	if len(item.Value) > leftPos {
+		c.mode = collectionMode(item.Value[leftPos])
+		leftPos += 1
+		if c.mode > collectionModeTimeSeries {
+			return fmt.Errorf("%w: unknown mode %d of collection %q", ErrCorrupted, c.mode, c.name)
+		}
+	}
+
+	if len(item.Value) > leftPos {
+		rollupsCount := int(item.Value[leftPos])
+		leftPos += rollupsCountSize
+		if leftPos+rollupsCount*rollupSize > len(item.Value) {
+			return fmt.Errorf("%w: rollups of collection %q don't fit its header", ErrCorrupted, c.name)
+		}
+		for i := 0; i < rollupsCount; i++ {
+			interval := time.Duration(binary.LittleEndian.Uint64(item.Value[leftPos:]))
+			leftPos += rollupIntervalSize
+			root := pgnum(binary.LittleEndian.Uint64(item.Value[leftPos:]))
+			leftPos += pageNumSize
+			c.rollups = append(c.rollups, newRollup(c, interval, root))
+		}
+	}
 
-		c.counter = binary.LittleEndian.Uint64(item.value[leftPos:])
-		leftPos += counterSize
+	if len(item.Value) > leftPos {
+		indexesCount := int(item.Value[leftPos])
+		leftPos += indexesCountSize
+		for i := 0; i < indexesCount; i++ {
+			if leftPos >= len(item.Value) {
+				return fmt.Errorf("%w: indexes of collection %q don't fit its header", ErrCorrupted, c.name)
+			}
+			nameLen := int(item.Value[leftPos])
+			leftPos += 1
+			if leftPos+nameLen+indexFlagsSize+pageNumSize > len(item.Value) {
+				return fmt.Errorf("%w: indexes of collection %q don't fit its header", ErrCorrupted, c.name)
+			}
+			name := item.Value[leftPos : leftPos+nameLen]
+			leftPos += nameLen
+			flags := item.Value[leftPos]
+			leftPos += indexFlagsSize
+			root := pgnum(binary.LittleEndian.Uint64(item.Value[leftPos:]))
+			leftPos += pageNumSize
+			c.indexes = append(c.indexes, newIndex(c, name, flags&indexFlagUnique != 0, root))
+		}
 	}
+	return nil
+}
+
+// persistRoot stores the collection root page after a split or merge moved it. The root collection (without a name)
+// keeps its root in the meta page, every other collection in its header inside the root collection. Trees owned by a
+// collection, like rollups, are stored in the header of their owner.
+func (c *Collection) persistRoot() error {
+	if c.owner != nil {
+		return c.owner.persistRoot()
+	}
+
+	if c.name == nil {
+		c.tx.root = c.rootNodePage
+		return nil
+	}
+
+	rootCollection := c.tx.getRootCollection()
+	return rootCollection.Put(c.name, c.serialize().Value)
 }
 
 //
-//func (c *Collection) serializeCustomCollection() *CustomItem {
+//func (c *Collection) serializeCustomCollection() *customItem {
 //	b := make([]byte, collectionSize)
 //	leftPos := 0
 //	binary.LittleEndian.PutUint64(b[leftPos:], uint64(c.rootNodePage))
@@ -69,7 +191,7 @@ func (c *Collection) deserialize(item *Item) {
 //}
 //
 
-//func (c *Collection) deserializeCustomCollection(item *CustomItem) {
+//func (c *Collection) deserializeCustomCollection(item *customItem) {
 //	c.name = item.key
 //
 //	if len(item.value) != 0 {
@@ -82,19 +204,56 @@ func (c *Collection) deserialize(item *Item) {
 //	}
 //}
 
+// Put inserts the item or replaces the value of an existing key. The indexes and rollups of the collection are updated
+// in the same transaction. If an index can't take the item, nothing is written: a unique index it violates returns a
+// *UniqueViolationError.
 func (c *Collection) Put(key []byte, value []byte) error {
-	if !c.tx.write {
-		return writeInsideReadTxErr
+	err := c.tx.writable()
+	if err != nil {
+		return err
+	}
+	if len(key) > maxKeySize {
+		return ErrKeyTooLarge
+	}
+	if len(value) > maxValueSize {
+		return ErrValueTooLarge
+	}
+
+	if len(c.indexes) == 0 && len(c.rollups) == 0 {
+		return c.put(key, value)
+	}
+
+	oldItem, err := c.Find(key)
+	if err != nil {
+		return err
 	}
 
+	// check the constraints before anything is written, so a violation leaves the transaction usable
+	updates, err := c.prepareIndexUpdates(key, oldItem, value)
+	if err != nil {
+		return err
+	}
+
+	err = c.put(key, value)
+	if err != nil {
+		return err
+	}
+	err = c.applyIndexUpdates(key, updates)
+	if err != nil {
+		return err
+	}
+	return c.updateRollups(key, oldItem, value)
+}
+
+func (c *Collection) put(key []byte, value []byte) error {
 	i := newItem(key, value)
 
-	var rootNodePage *Node
+	var rootNodePage *node
 	var err error
 	if c.rootNodePage == 0 {
 		rootNodePage = c.tx.createNode(c.tx.newNode([]*Item{i}, []pgnum{}))
 		c.rootNodePage = rootNodePage.pageNum
-		return nil
+		return c.persistRoot()
 	} else {
 		rootNodePage, err = c.tx.getNode(c.rootNodePage)
 		if err != nil {
@@ -102,12 +261,12 @@ func (c *Collection) Put(key []byte, value []byte) error {
 		}
 	}
 
-	insertionIndex, nodeToInsertIn, ancestorsIndexes, err := rootNodePage.findKey(i.key, false)
+	insertionIndex, nodeToInsertIn, ancestorsIndexes, err := rootNodePage.findKey(i.Key, false)
 	if err != nil {
 		return err
 	}
 
-	if nodeToInsertIn.items != nil && insertionIndex < len(nodeToInsertIn.items) && bytes.Compare(nodeToInsertIn.items[insertionIndex].key, key) == 0 {
+	if nodeToInsertIn.items != nil && insertionIndex < len(nodeToInsertIn.items) && bytes.Compare(nodeToInsertIn.items[insertionIndex].Key, key) == 0 {
 		nodeToInsertIn.items[insertionIndex] = i
 	} else {
 		nodeToInsertIn.addItem(i, insertionIndex)
@@ -119,29 +278,64 @@ func (c *Collection) Put(key []byte, value []byte) error {
 		return err
 	}
 
+	// appends to a time-series collection land in the last item of the right-most leaf
+	appending := c.mode == collectionModeTimeSeries && insertionIndex == len(nodeToInsertIn.items)-1 &&
+		isRightmostPath(ancestors, ancestorsIndexes)
+
 	for i := len(ancestors) - 2; i >= 0; i-- {
 		pnode := ancestors[i]
 		node := ancestors[i+1]
 		nodeIndex := ancestorsIndexes[i+1]
 		if node.isUpperBoundReached() {
-			pnode.split(node, nodeIndex)
+			if appending {
+				pnode.splitAtEnd(node, nodeIndex)
+			} else {
+				pnode.split(node, nodeIndex)
+			}
 		}
 	}
 
 	rootNode := ancestors[0]
 	if rootNode.isUpperBoundReached() {
-		newRoot := c.tx.newNode([]*Item{}, []pgnum{rootNode.pageNum})
-		newRoot.split(rootNode, 0)
+		return c.splitRoot(rootNode, appending)
+	}
 
-		newRoot = c.tx.createNode(newRoot)
+	return nil
+}
 
-		c.rootNodePage = newRoot.pageNum
+// splitRoot splits a root above the upper bound under a new root, which makes the tree one level deeper
+func (c *Collection) splitRoot(rootNode *node, appending bool) error {
+	newRoot := c.tx.newNode([]*Item{}, []pgnum{rootNode.pageNum})
+	if appending {
+		newRoot.splitAtEnd(rootNode, 0)
+	} else {
+		newRoot.split(rootNode, 0)
 	}
 
-	return nil
+	newRoot = c.tx.createNode(newRoot)
+
+	c.rootNodePage = newRoot.pageNum
+	return c.persistRoot()
+}
+
+func isRightmostPath(ancestors []*node, ancestorsIndexes []int) bool {
+	for i := 1; i < len(ancestors); i++ {
+		if ancestorsIndexes[i] != len(ancestors[i-1].childNodes)-1 {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *Collection) Find(key []byte) (*Item, error) {
+	err := c.tx.readable()
+	if err != nil {
+		return nil, err
+	}
+	if c.rootNodePage == 0 {
+		return nil, nil
+	}
+
 	n, err := c.tx.getNode(c.rootNodePage)
 	if err != nil {
 		return nil, err
@@ -157,24 +351,45 @@ func (c *Collection) Find(key []byte) (*Item, error) {
 	return containingNode.items[index], nil
 }
 
-func (c *Collection) FindAll(key []byte) (*Item, error) {
-	n, err := c.tx.getNode(c.rootNodePage)
+// Remove deletes the item with the given key and its entries in the indexes of the collection, nothing is removed if
+// an index can't be updated. The rollup buckets of a removed point are aggregated again without it.
+func (c *Collection) Remove(key []byte) error {
+	err := c.tx.writable()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if len(c.indexes) == 0 && len(c.rollups) == 0 {
+		return c.remove(key)
+	}
+
+	oldItem, err := c.Find(key)
+	if err != nil {
+		return err
+	}
+	if oldItem == nil {
+		return nil
 	}
-	indexes, nodes := n.findAllKeys(key, true)
-	print(indexes)
-	print(nodes)
+
+	updates, err := c.prepareIndexUpdates(key, oldItem, nil)
 	if err != nil {
+		return err
+	}
 
-		return nil, err
+	err = c.remove(key)
+	if err != nil {
+		return err
 	}
-	return nodes[0].items[0], nil
+	err = c.applyIndexUpdates(key, updates)
+	if err != nil {
+		return err
+	}
+	return c.updateRollups(key, oldItem, nil)
 }
 
-func (c *Collection) Remove(key []byte) error {
-	if !c.tx.write {
-		return writeInsideReadTxErr
+func (c *Collection) remove(key []byte) error {
+	if c.rootNodePage == 0 {
+		return nil
 	}
 
 	// Find the path to the node where the deletion should happen
@@ -216,27 +431,41 @@ func (c *Collection) Remove(key []byte) error {
 				return err
 			}
 		}
+
+		// rotations and the item moved up from a leaf can make the parent larger than it was
+		if i > 0 && pnode.isUpperBoundReached() {
+			ancestors[i-1].split(pnode, ancestorsIndexes[i])
+		}
 	}
 
 	rootNode = ancestors[0]
+	if rootNode.isUpperBoundReached() {
+		return c.splitRoot(rootNode, false)
+	}
 	// If the root has no items after rebalancing, there's no need to save it because we ignore it.
 	if len(rootNode.items) == 0 && len(rootNode.childNodes) > 0 {
-		c.rootNodePage = ancestors[1].pageNum
+		// a merge may have kept the left sibling of the path, the only child left is the new root
+		c.rootNodePage = rootNode.childNodes[0]
+		c.tx.deleteNode(rootNode)
+		return c.persistRoot()
 	}
 
 	return nil
 }
 
-func (c *Collection) getNodes(indexes []int) ([]*Node, error) {
+func (c *Collection) getNodes(indexes []int) ([]*node, error) {
 	rootNodePage, err := c.tx.getNode(c.rootNodePage)
 	if err != nil {
 		return nil, err
 	}
 
-	nodes := []*Node{rootNodePage}
+	nodes := []*node{rootNodePage}
 	child := rootNodePage
 	for i := 1; i < len(indexes); i++ {
-		child, _ = c.tx.getNode(child.childNodes[indexes[i]])
+		child, err = c.tx.getNode(child.childNodes[indexes[i]])
+		if err != nil {
+			return nil, err
+		}
 		nodes = append(nodes, child)
 	}
 	return nodes, nil
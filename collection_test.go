@@ -0,0 +1,402 @@
+package customdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// testNode is the shape of a node built by buildTestTree: the sizes of the keys and values of its items and its
+// children, none for a leaf
+type testNode struct {
+	items    []int
+	children []*testNode
+}
+
+// testLeaf returns a leaf with items of the given sizes
+func testLeaf(sizes ...int) *testNode {
+	return &testNode{items: sizes}
+}
+
+// testTree builds trees of items whose keys are numbered in key order, so the shape of the tree decides which items
+// are where
+type testTree struct {
+	tx   *Tx
+	keys [][]byte
+}
+
+// testTreeItem returns an item with a key and value of the given size, the key starts with its number
+func testTreeItem(number int, size int) *Item {
+	key := []byte(fmt.Sprintf("%04d", number))
+	key = append(key, bytes.Repeat([]byte("k"), size-len(key))...)
+	return newItem(key, bytes.Repeat([]byte("v"), size))
+}
+
+func (tree *testTree) build(shape *testNode) pgnum {
+	items := make([]*Item, 0, len(shape.items))
+	childNodes := make([]pgnum, 0, len(shape.children))
+	for i, size := range shape.items {
+		if len(shape.children) != 0 {
+			childNodes = append(childNodes, tree.build(shape.children[i]))
+		}
+		item := testTreeItem(len(tree.keys), size)
+		tree.keys = append(tree.keys, item.Key)
+		items = append(items, item)
+	}
+	if len(shape.children) != 0 {
+		childNodes = append(childNodes, tree.build(shape.children[len(shape.children)-1]))
+	}
+	return tree.tx.createNode(tree.tx.newNode(items, childNodes)).pageNum
+}
+
+// createTestTree creates a collection with a tree of the given shape and returns its keys in order
+func createTestTree(t *testing.T, db *DB, shape *testNode) [][]byte {
+	t.Helper()
+	tree := &testTree{}
+	err := db.Update(func(tx *Tx) error {
+		tree.tx = tx
+		_, err := tx.createCollection(newCollection(testCollectionName, tree.build(shape)))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+	return tree.keys
+}
+
+// removeTestKey removes the key and checks the file and that exactly the other keys are left
+func removeTestKey(t *testing.T, db *DB, keys [][]byte, removed int) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		return collection.Remove(keys[removed])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+
+	err = db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			item, err := collection.Find(key)
+			if err != nil {
+				return err
+			}
+			if (item != nil) != (i != removed) {
+				t.Errorf("expected key %d to exist: %v", i, i != removed)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// items of this size fill the nodes of the test params: 2 are above the lower bound and 4 below the upper one
+const bigTestItem = 255
+
+func TestCollection_RemoveCollapsesRoot(t *testing.T) {
+	for _, removed := range []int{0, 4} {
+		t.Run(fmt.Sprint(removed), func(t *testing.T) {
+			db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+			defer db.Close()
+
+			// removing any leaf item merges the leaves into the only child of the root, the right one is merged into
+			// the left one
+			keys := createTestTree(t, db, &testNode{
+				items: []int{bigTestItem},
+				children: []*testNode{
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+				},
+			})
+			removeTestKey(t, db, keys, removed)
+		})
+	}
+}
+
+func TestCollection_RemoveFromBranch(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	// the item of the root is replaced by the last item of its left subtree, whose nodes have more children than the
+	// root
+	keys := createTestTree(t, db, &testNode{
+		items: []int{bigTestItem},
+		children: []*testNode{
+			{
+				items: []int{bigTestItem, bigTestItem},
+				children: []*testNode{
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+				},
+			},
+			{
+				items: []int{bigTestItem, bigTestItem, bigTestItem},
+				children: []*testNode{
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+					testLeaf(bigTestItem, bigTestItem),
+				},
+			},
+		},
+	})
+	removeTestKey(t, db, keys, 8)
+}
+
+// items of this size are small enough for many to fit a node
+const smallTestItem = 25
+
+// repeatTestItem returns count items of the size
+func repeatTestItem(size int, count int) []int {
+	sizes := make([]int, count)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes
+}
+
+func TestCollection_RemoveMergeAboveUpperBound(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	// the right leaf can't spare its small items, they are below the lower bound without the big one, but merged with
+	// the left leaf and the item of the root they're above the upper bound
+	keys := createTestTree(t, db, &testNode{
+		items: []int{bigTestItem},
+		children: []*testNode{
+			testLeaf(bigTestItem, bigTestItem),
+			testLeaf(append(repeatTestItem(smallTestItem, 15), bigTestItem)...),
+		},
+	})
+	removeTestKey(t, db, keys, 0)
+}
+
+// testBranch returns a node with small items between the children
+func testBranch(children ...*testNode) *testNode {
+	return &testNode{items: repeatTestItem(smallTestItem, len(children)-1), children: children}
+}
+
+// testTreeItems returns the number of items of a tree of the shape
+func testTreeItems(shape *testNode) int {
+	count := len(shape.items)
+	for _, child := range shape.children {
+		count += testTreeItems(child)
+	}
+	return count
+}
+
+// rotationTestBranch returns a node that is just below the upper bound with small items, whose last leaf is below the
+// lower bound without its last item and gets a big item of its left sibling through the node
+func rotationTestBranch() *testNode {
+	children := make([]*testNode, 0, 37)
+	for len(children) < 35 {
+		children = append(children, testLeaf(repeatTestItem(smallTestItem, 16)...))
+	}
+	children = append(children, testLeaf(bigTestItem, bigTestItem, bigTestItem), testLeaf(bigTestItem, bigTestItem))
+	return testBranch(children...)
+}
+
+func TestCollection_RemoveRotationAboveUpperBound(t *testing.T) {
+	t.Run("root", func(t *testing.T) {
+		db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+		defer db.Close()
+
+		shape := rotationTestBranch()
+		keys := createTestTree(t, db, shape)
+		removeTestKey(t, db, keys, testTreeItems(shape)-1)
+	})
+
+	t.Run("branch", func(t *testing.T) {
+		db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+		defer db.Close()
+
+		branch := rotationTestBranch()
+		leaves := make([]*testNode, 15)
+		for i := range leaves {
+			leaves[i] = testLeaf(repeatTestItem(smallTestItem, 16)...)
+		}
+		keys := createTestTree(t, db, testBranch(branch, testBranch(leaves...)))
+		removeTestKey(t, db, keys, testTreeItems(branch)-1)
+	})
+}
+
+// putTestSequence creates a collection and puts count items of 44 bytes in it, in ascending or descending key order
+func putTestSequence(t *testing.T, db *DB, timeSeries bool, count int, ascending bool) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		create := tx.CreateCollection
+		if timeSeries {
+			create = tx.CreateTimeSeriesCollection
+		}
+		collection, err := create(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			number := i
+			if !ascending {
+				number = count - i
+			}
+			err = collection.Put([]byte(fmt.Sprintf("%08d", number)), bytes.Repeat([]byte("v"), 32))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+}
+
+// testLeafSizes returns the sizes of the leaves of the test collection, in key order
+func testLeafSizes(t *testing.T, db *DB) []int {
+	t.Helper()
+	var sizes []int
+	var walk func(tx *Tx, page pgnum) error
+	walk = func(tx *Tx, page pgnum) error {
+		node, err := tx.getNode(page)
+		if err != nil {
+			return err
+		}
+		if node.isLeaf() {
+			sizes = append(sizes, node.nodeSize())
+		}
+		for _, child := range node.childNodes {
+			err = walk(tx, child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		return walk(tx, collection.rootNodePage)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sizes
+}
+
+func openTestAppendDB(t *testing.T, appendStored float32) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "db"), &Params{MinStored: 0.5, MaxStored: 0.95, AppendStored: appendStored})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCollection_AppendSplitsAtEnd(t *testing.T) {
+	for _, appendStored := range []float32{0.7, 0.9} {
+		t.Run(fmt.Sprint(appendStored), func(t *testing.T) {
+			db := openTestAppendDB(t, appendStored)
+			defer db.Close()
+			putTestSequence(t, db, true, 2000, true)
+
+			// every leaf but the right-most one is as full as the fill of appends allows
+			appendRange := appendStored * float32(db.pSize)
+			sizes := testLeafSizes(t, db)
+			if len(sizes) < 3 {
+				t.Fatalf("expected the items to fill several leaves, got %d", len(sizes))
+			}
+			for i, size := range sizes[:len(sizes)-1] {
+				if float32(size) > appendRange || float32(size) < appendRange-44 {
+					t.Errorf("leaf %d of %d bytes isn't filled to %.0f bytes", i, size, appendRange)
+				}
+			}
+		})
+	}
+}
+
+func TestCollection_AppendFillsMoreThanDefault(t *testing.T) {
+	fill := func(timeSeries bool) float64 {
+		db := openTestAppendDB(t, 0.9)
+		defer db.Close()
+		putTestSequence(t, db, timeSeries, 2000, true)
+		sizes := testLeafSizes(t, db)
+		total := 0
+		for _, size := range sizes {
+			total += size
+		}
+		return float64(total) / float64(len(sizes)*db.pSize)
+	}
+
+	timeSeries, regular := fill(true), fill(false)
+	if timeSeries < 0.85 || regular > 0.6 {
+		t.Fatalf("expected leaves filled to 90%% by appends and 50%% otherwise, got %.2f and %.2f", timeSeries, regular)
+	}
+}
+
+func TestCollection_NonAppendSplitsInMiddle(t *testing.T) {
+	sizes := func(timeSeries bool) []int {
+		db := openTestAppendDB(t, 0.9)
+		defer db.Close()
+		putTestSequence(t, db, timeSeries, 2000, false)
+		return testLeafSizes(t, db)
+	}
+
+	// inserts before the last key split like in any other collection
+	timeSeries, regular := sizes(true), sizes(false)
+	if fmt.Sprint(timeSeries) != fmt.Sprint(regular) {
+		t.Fatalf("expected the leaves of a default collection %v, got %v", regular, timeSeries)
+	}
+}
+
+func TestCollection_PutErrors(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	err := db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		run      func(fn func(*Tx) error) error
+		key      []byte
+		value    []byte
+		expected error
+	}{
+		{name: "key", run: db.Update, key: make([]byte, maxKeySize+1), value: []byte("1"), expected: ErrKeyTooLarge},
+		{name: "value", run: db.Update, key: []byte("1"), value: make([]byte, maxValueSize+1), expected: ErrValueTooLarge},
+		{name: "read", run: db.View, key: []byte("1"), value: []byte("1"), expected: ErrTxNotWritable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.run(func(tx *Tx) error {
+				collection, err := tx.GetCollection(testCollectionName)
+				if err != nil {
+					return err
+				}
+				return collection.Put(test.key, test.value)
+			})
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
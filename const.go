@@ -1,14 +1,69 @@
-package main
+package customdb
 
 import "errors"
 
 const (
 	magicNumberSize = 4
-	counterSize     = 4
+	counterSize     = 8
 	nodeHeaderSize  = 3
+	// offset, key length and value length of an item
+	itemOverheadSize = 4
 
-	collectionSize = 16
+	// root page, counter and mode
+	collectionSize = 17
 	pageNumSize    = 8
+
+	rollupsCountSize   = 1
+	rollupIntervalSize = 8
+	rollupSize         = rollupIntervalSize + pageNumSize
+
+	indexesCountSize = 1
+	indexFlagsSize   = 1
+
+	// keys and values store their length in one byte
+	maxKeySize    = 255
+	maxValueSize  = 255
+	maxHeaderSize = maxValueSize
 )
 
-var writeInsideReadTxErr = errors.New("can't perform a write operation inside a read transaction")
+var (
+	// ErrCorrupted is matched by the errors of pages and headers that can't be decoded
+	ErrCorrupted = errors.New("database file is corrupted")
+	// ErrTxClosed is returned when a transaction is used after Commit or Rollback
+	ErrTxClosed = errors.New("transaction is closed")
+	// ErrCollectionExists is returned when creating a collection with the name of an existing one
+	ErrCollectionExists = errors.New("collection already exists")
+	// ErrCollectionNotFound is returned when getting or deleting a collection that doesn't exist
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrKeyTooLarge is returned when a key doesn't fit the one byte that stores its length
+	ErrKeyTooLarge = errors.New("key is too large")
+	// ErrValueTooLarge is returned when a value doesn't fit the one byte that stores its length
+	ErrValueTooLarge = errors.New("value is too large")
+	// ErrTxNotWritable is returned when writing inside a read transaction
+	ErrTxNotWritable = errors.New("can't perform a write operation inside a read transaction")
+	// ErrDatabaseClosed is returned when the statistics of a closed database are asked for
+	ErrDatabaseClosed = errors.New("database is closed")
+	// ErrDatabaseReadOnly is returned when writing to a database opened with Params.ReadOnly
+	ErrDatabaseReadOnly = errors.New("database is opened read-only")
+	// ErrUniqueViolation is matched by the *UniqueViolationError returned when a unique index rejects an item
+	ErrUniqueViolation = errors.New("unique index violation")
+	// ErrIndexNotRegistered is matched by the errors of writes to a collection with an index whose extractor wasn't
+	// registered with CreateIndex since the database was opened
+	ErrIndexNotRegistered = errors.New("index has no extractor, call CreateIndex after opening the database")
+
+	// tells a Batch call that its function failed the shared transaction and has to run in its own one
+	trySoloErr           = errors.New("batch function returned an error and should be re-run solo")
+	invalidSavepointErr  = errors.New("savepoint doesn't belong to this transaction or was released")
+	managedTxErr         = errors.New("can't commit or roll back a transaction managed by Update or View")
+	seriesTooLongErr     = errors.New("series name is too long for a point key")
+	invalidPointKeyErr   = errors.New("key is not a time-series point key")
+	invalidPointValueErr = errors.New("value is not a time-series point value")
+	invalidBucketErr     = errors.New("bucket size must be positive")
+	rollupExistsErr      = errors.New("rollup with this interval already exists")
+	headerTooLargeErr    = errors.New("collection header is too large")
+	indexExistsErr       = errors.New("index with this name already exists")
+	indexNotFoundErr     = errors.New("index with this name doesn't exist")
+	indexKeyTooLargeErr  = errors.New("indexed value and key are too large for an index entry")
+	freelistFullErr      = errors.New("freelist page can't hold the free pages")
+	fileTooLargeErr      = errors.New("database file has more pages than the freelist can address")
+)
@@ -0,0 +1,167 @@
+package customdb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVOptions are the options of ImportCSV.
+type CSVOptions struct {
+	// Key is the column of the keys, or a template combining columns in braces, like "{ts}:{name}". The first column is
+	// the key if it's empty.
+	Key string
+	// Value is the column stored as the value, the whole row is stored as a JSON object if it's empty
+	Value string
+	// Comma is the field delimiter, ',' if it's zero
+	Comma rune
+	// BatchSize is the number of rows written in a transaction, 1000 if it's not positive
+	BatchSize int
+}
+
+// keyTemplate builds the keys of the rows, from literal parts and columns
+type keyTemplate struct {
+	literals []string
+	// columns[i] is the index of the column after literals[i], -1 after the last literal
+	columns []int
+}
+
+func parseKeyTemplate(template string, columns map[string]int) (*keyTemplate, error) {
+	if !strings.Contains(template, "{") {
+		template = "{" + template + "}"
+	}
+
+	t := &keyTemplate{}
+	for {
+		start := strings.IndexByte(template, '{')
+		if start == -1 {
+			t.literals = append(t.literals, template)
+			t.columns = append(t.columns, -1)
+			return t, nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("key template: unclosed { in %q", template)
+		}
+		name := template[start+1 : start+end]
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("key template: no column %q", name)
+		}
+		t.literals = append(t.literals, template[:start])
+		t.columns = append(t.columns, column)
+		template = template[start+end+1:]
+	}
+}
+
+func (t *keyTemplate) key(record []string) []byte {
+	var key []byte
+	for i, literal := range t.literals {
+		key = append(key, literal...)
+		if t.columns[i] != -1 {
+			key = append(key, record[t.columns[i]]...)
+		}
+	}
+	return key
+}
+
+// rowObject encodes a row as a JSON object of the header, in the order of the columns
+func rowObject(header []string, record []string) ([]byte, error) {
+	value := []byte{'{'}
+	for i, name := range header {
+		if i > 0 {
+			value = append(value, ',')
+		}
+		field, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, field...)
+		value = append(value, ':')
+		field, err = json.Marshal(record[i])
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, field...)
+	}
+	return append(value, '}'), nil
+}
+
+// ImportCSV puts the rows of a CSV file with a header line in a collection, creating it if it doesn't exist. The key of
+// a row is made from its columns by opts.Key, its value is the column opts.Value or the whole row as a JSON object.
+// Rows with the same key overwrite each other. The rows are written in transactions of opts.BatchSize rows, it returns
+// the number of rows imported. On an error the batches committed before it stay. A nil opts uses the defaults of
+// every option. Like Import, it fails with ErrIndexNotRegistered on a collection whose index extractors weren't
+// registered.
+func (db *DB) ImportCSV(r io.Reader, collection []byte, opts *CSVOptions) (int, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// set after reading the header, which rowObject keeps using, so only the rows share a slice
+	reader.ReuseRecord = true
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := columns[name]; ok {
+			return 0, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	key := &keyTemplate{literals: []string{"", ""}, columns: []int{0, -1}}
+	if opts.Key != "" {
+		key, err = parseKeyTemplate(opts.Key, columns)
+		if err != nil {
+			return 0, err
+		}
+	}
+	valueColumn := -1
+	if opts.Value != "" {
+		var ok bool
+		valueColumn, ok = columns[opts.Value]
+		if !ok {
+			return 0, fmt.Errorf("no value column %q", opts.Value)
+		}
+	}
+
+	w := db.newBatchWriter(opts.BatchSize)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.abort()
+			return w.committed, err
+		}
+
+		var value []byte
+		if valueColumn != -1 {
+			value = []byte(record[valueColumn])
+		} else {
+			value, err = rowObject(header, record)
+		}
+		if err == nil {
+			err = w.put(collection, key.key(record), value)
+		}
+		if err != nil {
+			w.abort()
+			line, _ := reader.FieldPos(0)
+			return w.committed, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return w.flush()
+}
@@ -0,0 +1,105 @@
+package customdb
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCSV = "id,name\n1,ann\n2,bob\n"
+
+// readTestCollection returns the items of the test collection by key
+func readTestCollection(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	items := map[string]string{}
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		cursor := collection.Cursor()
+		item, err := cursor.First()
+		for ; err == nil && item != nil; item, err = cursor.Next() {
+			items[string(item.Key)] = string(item.Value)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestDB_ImportCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *CSVOptions
+		expected map[string]string
+	}{
+		{
+			name: "defaults",
+			opts: nil,
+			expected: map[string]string{
+				"1": `{"id":"1","name":"ann"}`,
+				"2": `{"id":"2","name":"bob"}`,
+			},
+		},
+		{
+			name:     "column",
+			opts:     &CSVOptions{Key: "name", Value: "id"},
+			expected: map[string]string{"ann": "1", "bob": "2"},
+		},
+		{
+			name:     "template",
+			opts:     &CSVOptions{Key: "user/{name}:{id}", Value: "id"},
+			expected: map[string]string{"user/ann:1": "1", "user/bob:2": "2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+			defer db.Close()
+
+			count, err := db.ImportCSV(strings.NewReader(testCSV), testCollectionName, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("expected 2 rows to be imported, got %d", count)
+			}
+			if items := readTestCollection(t, db); !reflect.DeepEqual(items, test.expected) {
+				t.Errorf("expected items %v, got %v", test.expected, items)
+			}
+		})
+	}
+}
+
+func TestDB_ImportCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		opts  *CSVOptions
+		error string
+	}{
+		{name: "unknown key column", csv: testCSV, opts: &CSVOptions{Key: "{id}:{age}"}, error: `no column "age"`},
+		{name: "unclosed brace", csv: testCSV, opts: &CSVOptions{Key: "{id}:{name"}, error: "unclosed {"},
+		{name: "unknown value column", csv: testCSV, opts: &CSVOptions{Value: "age"}, error: `no value column "age"`},
+		{name: "duplicate column", csv: "id,id\n1,2\n", error: `duplicate column "id"`},
+		{name: "field count", csv: testCSV + "3,cid,x\n", error: "wrong number of fields"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+			defer db.Close()
+
+			count, err := db.ImportCSV(strings.NewReader(test.csv), testCollectionName, test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Fatalf("expected an error with %q, got %v", test.error, err)
+			}
+			if count != 0 {
+				t.Errorf("expected no rows to be imported, got %d", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package customdb
+
+// cursorFrame is a node on the path from the root to the current item. index is the item of the node the cursor is at
+// or, for branch nodes, the item it will return after the subtree of childNodes[index].
+type cursorFrame struct {
+	node  *node
+	index int
+}
+
+// Cursor walks the items of a collection in key order. Only the path from the root to the current item is kept in
+// memory, so a scan over a large collection doesn't load all its nodes. Every move fails with ErrTxClosed once the
+// transaction is finished and with ctx.Err() once its context is done.
+type Cursor struct {
+	collection *Collection
+	stack      []cursorFrame
+}
+
+func (c *Collection) Cursor() *Cursor {
+	return &Cursor{
+		collection: c,
+	}
+}
+
+// First moves the cursor to the smallest key of the collection. It returns nil if the collection is empty.
+func (cur *Cursor) First() (*Item, error) {
+	err := cur.collection.tx.readable()
+	if err != nil {
+		return nil, err
+	}
+	cur.stack = cur.stack[:0]
+	if cur.collection.rootNodePage == 0 {
+		return nil, nil
+	}
+
+	node, err := cur.collection.tx.getNode(cur.collection.rootNodePage)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cur.descendFirst(node)
+	if err != nil {
+		return nil, err
+	}
+	return cur.current()
+}
+
+// Seek moves the cursor to the given key or, if it doesn't exist, to the next bigger key. It returns nil if there is
+// no such key.
+func (cur *Cursor) Seek(key []byte) (*Item, error) {
+	err := cur.collection.tx.readable()
+	if err != nil {
+		return nil, err
+	}
+	cur.stack = cur.stack[:0]
+	if cur.collection.rootNodePage == 0 {
+		return nil, nil
+	}
+
+	node, err := cur.collection.tx.getNode(cur.collection.rootNodePage)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		wasFound, index := node.findKeyInNode(key)
+		cur.stack = append(cur.stack, cursorFrame{node: node, index: index})
+		if wasFound || node.isLeaf() {
+			return cur.current()
+		}
+
+		node, err = node.getNode(node.childNodes[index])
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Next moves the cursor to the next key. It returns nil after the last key.
+func (cur *Cursor) Next() (*Item, error) {
+	err := cur.collection.tx.readable()
+	if err != nil {
+		return nil, err
+	}
+	if len(cur.stack) == 0 {
+		return nil, nil
+	}
+
+	top := &cur.stack[len(cur.stack)-1]
+	top.index++
+	if !top.node.isLeaf() {
+		// the next item is the smallest one of the subtree right to the current item
+		child, err := top.node.getNode(top.node.childNodes[top.index])
+		if err != nil {
+			return nil, err
+		}
+
+		err = cur.descendFirst(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cur.current()
+}
+
+func (cur *Cursor) descendFirst(node *node) error {
+	for {
+		cur.stack = append(cur.stack, cursorFrame{node: node, index: 0})
+		if node.isLeaf() {
+			return nil
+		}
+
+		child, err := node.getNode(node.childNodes[0])
+		if err != nil {
+			return err
+		}
+		node = child
+	}
+}
+
+// current returns the item the cursor is at. If the cursor is past the end of a node, it goes up to the first ancestor
+// that still has items left.
+func (cur *Cursor) current() (*Item, error) {
+	for len(cur.stack) > 0 {
+		top := cur.stack[len(cur.stack)-1]
+		if top.index < len(top.node.items) {
+			return top.node.items[top.index], nil
+		}
+		cur.stack = cur.stack[:len(cur.stack)-1]
+	}
+	return nil, nil
+}
@@ -0,0 +1,120 @@
+package customdb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// cursorTestShape has items in the root, branches and leaves
+var cursorTestShape = &testNode{
+	items: []int{bigTestItem},
+	children: []*testNode{
+		{
+			items:    []int{bigTestItem, bigTestItem},
+			children: []*testNode{testLeaf(bigTestItem, bigTestItem), testLeaf(bigTestItem), testLeaf(bigTestItem)},
+		},
+		{
+			items:    []int{bigTestItem},
+			children: []*testNode{testLeaf(bigTestItem, bigTestItem), testLeaf(bigTestItem, bigTestItem)},
+		},
+	},
+}
+
+// scanTestCursor returns the items from the one the cursor is at to the last one
+func scanTestCursor(cursor *Cursor, item *Item, err error) ([][]byte, error) {
+	var keys [][]byte
+	for ; err == nil && item != nil; item, err = cursor.Next() {
+		keys = append(keys, item.Key)
+	}
+	return keys, err
+}
+
+func TestCursor_Seek(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	keys := createTestTree(t, db, cursorTestShape)
+
+	tests := []struct {
+		name string
+		seek []byte
+		// index of the first key found, len(keys) for none
+		first int
+	}{
+		{name: "before the first key", seek: []byte("0"), first: 0},
+		{name: "past the last key", seek: []byte("9999"), first: len(keys)},
+	}
+	for i, key := range keys {
+		tests = append(tests,
+			struct {
+				name  string
+				seek  []byte
+				first int
+			}{name: fmt.Sprintf("key %d", i), seek: key, first: i},
+			struct {
+				name  string
+				seek  []byte
+				first int
+			}{name: fmt.Sprintf("after key %d", i), seek: append(key[:4:4], 'z'), first: i + 1},
+		)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := db.View(func(tx *Tx) error {
+				collection, err := tx.GetCollection(testCollectionName)
+				if err != nil {
+					return err
+				}
+				cursor := collection.Cursor()
+				item, err := cursor.Seek(test.seek)
+				found, err := scanTestCursor(cursor, item, err)
+				if err != nil {
+					return err
+				}
+
+				expected := keys[test.first:]
+				if len(found) != len(expected) {
+					t.Fatalf("expected %d keys from the seek, got %d", len(expected), len(found))
+				}
+				for i := range found {
+					if !bytes.Equal(found[i], expected[i]) {
+						t.Fatalf("expected key %d of the scan to be %.4s, got %.4s", i, expected[i], found[i])
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCursor_Empty(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.CreateCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		cursor := collection.Cursor()
+		for name, move := range map[string]func() (*Item, error){
+			"First": cursor.First,
+			"Seek":  func() (*Item, error) { return cursor.Seek([]byte("a")) },
+			"Next":  cursor.Next,
+		} {
+			item, err := move()
+			if err != nil || item != nil {
+				t.Errorf("expected %s on an empty collection to return nothing, got %v, %v", name, item, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,11 @@
-package main
+package customdb
 
 import (
 	"errors"
 	"fmt"
 	"os"
+	"sync/atomic"
+	"time"
 )
 
 type pgnum uint64
@@ -14,11 +16,50 @@ type Params struct {
 	//in percents
 	MinStored float32
 	MaxStored float32
+	// AppendStored is the fill of nodes split by right-most appends in time-series collections, MaxStored if not set
+	AppendStored float32
+
+	// MaxBatchSize is the number of DB.Batch calls sharing a transaction, MaxBatchDelay how long the first call waits
+	// for others. Zero values use the defaults.
+	MaxBatchSize  int
+	MaxBatchDelay time.Duration
+
+	// Durability decides when commits are flushed to the disk, SyncInterval is the period of DurabilityPeriodic
+	Durability   Durability
+	SyncInterval time.Duration
+
+	// ReadOnly opens an existing file without write access, write operations fail with ErrDatabaseReadOnly
+	ReadOnly bool
+
+	// Logger receives the events of the database, nothing is logged if it's nil
+	Logger Logger
 }
 
+// Durability is the trade between the latency of commits and the commits lost on a crash of the machine.
+type Durability int
+
+const (
+	// DurabilityFull syncs the file on every commit
+	DurabilityFull Durability = iota
+	// DurabilityPeriodic syncs the file in the background every SyncInterval, crashes lose the commits since the last sync
+	DurabilityPeriodic
+	// DurabilityNone leaves flushing to the operating system, for bulk loads that can be redone
+	DurabilityNone
+)
+
+const defaultSyncInterval = time.Second
+
+const (
+	defaultMaxBatchSize  = 1000
+	defaultMaxBatchDelay = 10 * time.Millisecond
+)
+
 var DefaultParams = &Params{
-	MinStored: 0.5,
-	MaxStored: 0.95,
+	MinStored:     0.5,
+	MaxStored:     0.95,
+	AppendStored:  0.9,
+	MaxBatchSize:  defaultMaxBatchSize,
+	MaxBatchDelay: defaultMaxBatchDelay,
 }
 
 type page struct {
@@ -27,10 +68,18 @@ type page struct {
 }
 
 type dal struct {
-	pSize     int
-	minStored float32
-	maxStored float32
-	file      *os.File
+	pSize        int
+	minStored    float32
+	maxStored    float32
+	appendStored float32
+	durability   Durability
+	readOnly     bool
+	logger       Logger
+	file         *os.File
+	// pages were written since the last sync
+	dirty atomic.Bool
+
+	stats counters
 
 	*meta
 	*freelist
@@ -38,10 +87,20 @@ type dal struct {
 
 func fillNewDalObject(Params *Params) *dal {
 	dal := &dal{
-		meta:      newEmptyMeta(),
-		pSize:     Params.pSize,
-		minStored: Params.MinStored,
-		maxStored: Params.MaxStored,
+		meta:         newEmptyMeta(),
+		pSize:        Params.pSize,
+		minStored:    Params.MinStored,
+		maxStored:    Params.MaxStored,
+		appendStored: Params.AppendStored,
+		durability:   Params.Durability,
+		readOnly:     Params.ReadOnly,
+		logger:       Params.Logger,
+	}
+	if dal.logger == nil {
+		dal.logger = discardLogger{}
+	}
+	if dal.appendStored == 0 || dal.appendStored > dal.maxStored {
+		dal.appendStored = dal.maxStored
 	}
 	return dal
 }
@@ -49,7 +108,11 @@ func newDal(path string, Params *Params) (*dal, error) {
 	dal := fillNewDalObject(Params)
 
 	if _, err := os.Stat(path); err == nil {
-		dal.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+		flag := os.O_RDWR | os.O_CREATE
+		if dal.readOnly {
+			flag = os.O_RDONLY
+		}
+		dal.file, err = os.OpenFile(path, flag, 0666)
 		if err != nil {
 			_ = dal.close()
 			return nil, err
@@ -57,17 +120,23 @@ func newDal(path string, Params *Params) (*dal, error) {
 
 		meta, err := dal.parseMeta()
 		if err != nil {
+			_ = dal.close()
 			return nil, err
 		}
 		dal.meta = meta
 
 		freelist, err := dal.parseFreeList()
 		if err != nil {
+			_ = dal.close()
 			return nil, err
 		}
 		dal.freelist = freelist
 		// doesn't exist
 	} else if errors.Is(err, os.ErrNotExist) {
+		if dal.readOnly {
+			return nil, err
+		}
+
 		// init freelist
 		dal.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
 		if err != nil {
@@ -76,40 +145,48 @@ func newDal(path string, Params *Params) (*dal, error) {
 		}
 
 		dal.freelist = setFreeList()
-		dal.freelistPage = dal.AllocateNewPage()
-		_, err := dal.updateFreeList()
+		dal.freelistPage = dal.allocateNewPage()
+
+		// init root
+		collectionsNode, err := dal.createNode(newNodeForSerialization([]*Item{}, []pgnum{}))
+		//collectionsNode, err := dal.createNode(newNodeForSerialization([]*customItem{}, []pgnum{}))
 		if err != nil {
+			_ = dal.close()
 			return nil, err
 		}
+		dal.root = collectionsNode.pageNum
+		dal.logger.Info("database file created", "path", path, "pageSize", dal.pSize)
 
-		// init root
-		collectionsNode, err := dal.createNode(NewNodeForSerialization([]*Item{}, []pgnum{}))
-		//collectionsNode, err := dal.createNode(NewNodeForSerialization([]*CustomItem{}, []pgnum{}))
+		// written after the root, so the freelist counts its page
+		_, err = dal.updateFreeList()
 		if err != nil {
+			_ = dal.close()
 			return nil, err
 		}
-		dal.root = collectionsNode.pageNum
 
 		// write meta page
-		_, err = dal.updateMeta(dal.meta) // other error
+		_, err = dal.updateMeta(dal.meta)
+		if err != nil {
+			_ = dal.close()
+			return nil, err
+		}
 	} else {
 		return nil, err
 	}
+	dal.stats.pagesChanged(dal.freelist)
 	return dal, nil
 }
 
 // used in node to rebalance
-func (d *dal) findBalanceIndex(node *Node) int {
+func (d *dal) findBalanceIndex(node *node) int {
 	size := 0
 	size += nodeHeaderSize
-	println("minRange", d.minRange())
 
 	for i := range node.items {
 		size += node.elementSize(i)
 
 		// if we have a big enough page size (more than minimum), and didn't reach the last node, which means we can
 		// spare an element
-		println("size : ", size)
 		if float32(size) > d.minRange() && i < len(node.items)-1 {
 			return i + 1
 		}
@@ -118,13 +195,42 @@ func (d *dal) findBalanceIndex(node *Node) int {
 	return -1
 }
 
+// used in node to split the right-most node of a time-series collection. The left node keeps as many items as fit in
+// appendRange and only the tail moves to the new node, so nodes filled by appends don't stay half empty.
+func (d *dal) findAppendSplitIndex(node *node) int {
+	if len(node.items) < 3 {
+		return d.findBalanceIndex(node)
+	}
+
+	// counted like nodeSize, so the left node ends up at appendRange
+	size := nodeHeaderSize + 1
+	if !node.isLeaf() {
+		size += pageNumSize
+	}
+	// keep at least one item for the new right-most node
+	last := len(node.items) - 2
+	for i := 0; i < last; i++ {
+		size += node.elementSize(i)
+		if float32(size) > d.appendRange() {
+			if i == 0 {
+				return 1
+			}
+			return i
+		}
+	}
+
+	return last
+}
+
+func (d *dal) appendRange() float32 {
+	return d.appendStored * float32(d.pSize)
+}
+
 func (d *dal) maxRange() float32 {
 	return d.maxStored * float32(d.pSize)
 }
 
-func (d *dal) isUpperBoundReached(node *Node) bool {
-	println("nodeSize : ", float32(node.nodeSize()))
-	println("maxRange : ", d.maxRange())
+func (d *dal) isUpperBoundReached(node *node) bool {
 	return float32(node.nodeSize()) > d.maxRange()
 }
 
@@ -132,7 +238,7 @@ func (d *dal) minRange() float32 {
 	return d.minStored * float32(d.pSize)
 }
 
-func (d *dal) isLowerBoundReached(node *Node) bool {
+func (d *dal) isLowerBoundReached(node *node) bool {
 	return float32(node.nodeSize()) < d.minRange()
 }
 
@@ -140,7 +246,7 @@ func (d *dal) close() error {
 	if d.file != nil {
 		err := d.file.Close()
 		if err != nil {
-			return fmt.Errorf("can't close db file: %s", err)
+			return fmt.Errorf("can't close db file: %w", err)
 		}
 		d.file = nil
 	}
@@ -155,6 +261,10 @@ func (d *dal) allocateEmptyPage() *page {
 }
 
 func (d *dal) readPage(pageNum pgnum) (*page, error) {
+	if d.freelist != nil && pageNum > d.maxAllowedPage {
+		return nil, fmt.Errorf("%w: page %d is past the end of the file", ErrCorrupted, pageNum)
+	}
+	d.stats.pageReads.Add(1)
 	p := d.allocateEmptyPage()
 
 	offset := int(pageNum) * d.pSize
@@ -168,26 +278,49 @@ func (d *dal) readPage(pageNum pgnum) (*page, error) {
 func (d *dal) writePage(p *page) error {
 	offset := int64(p.num) * int64(d.pSize)
 	_, err := d.file.WriteAt(p.data, offset)
+	d.dirty.Store(true)
+	d.stats.pageWrites.Add(1)
 	return err
 }
 
-func (d *dal) getNode(pageNum pgnum) (*Node, error) {
+// syncFile flushes a file to the disk, tests replace it to make syncs fail
+var syncFile = (*os.File).Sync
+
+// sync flushes the written pages to the disk
+func (d *dal) sync() error {
+	if !d.dirty.Swap(false) {
+		return nil
+	}
+
+	err := syncFile(d.file)
+	if err != nil {
+		d.dirty.Store(true)
+		return fmt.Errorf("can't sync db file: %w", err)
+	}
+	return nil
+}
+
+func (d *dal) getNode(pageNum pgnum) (*node, error) {
+	if pageNum == metaPageNum || pageNum == d.freelistPage {
+		return nil, fmt.Errorf("%w: page %d isn't a node", ErrCorrupted, pageNum)
+	}
 	p, err := d.readPage(pageNum)
 	if err != nil {
 		return nil, err
 	}
-	node := NewEmptyNode()
-	node.deserialize(p.data)
-	fmt.Printf("items : %s, childNodes: %s", node.items, node.childNodes)
-	fmt.Printf("node : %s\n", node)
+	node := newEmptyNode()
+	err = node.deserialize(p.data)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", pageNum, err)
+	}
 	node.pageNum = pageNum
 	return node, nil
 }
 
-func (d *dal) createNode(n *Node) (*Node, error) {
+func (d *dal) createNode(n *node) (*node, error) {
 	p := d.allocateEmptyPage()
 	if n.pageNum == 0 {
-		p.num = d.AllocateNewPage()
+		p.num = d.allocateNewPage()
 		n.pageNum = p.num
 	} else {
 		p.num = n.pageNum
@@ -213,20 +346,34 @@ func (d *dal) parseFreeList() (*freelist, error) {
 	}
 
 	freelist := setFreeList()
-	freelist.deserialize(p.data)
+	err = freelist.deserialize(p.data)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", d.freelistPage, err)
+	}
 	return freelist, nil
 }
 
 func (d *dal) updateFreeList() (*page, error) {
+	p, err := d.serializeFreeList()
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.writePage(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// serializeFreeList returns the page of the freelist without writing it, failing if the freelist doesn't fit it
+func (d *dal) serializeFreeList() (*page, error) {
 	p := d.allocateEmptyPage()
 	p.num = d.freelistPage
-	d.freelist.serialize(p.data)
-
-	err := d.writePage(p)
+	_, err := d.freelist.serialize(p.data)
 	if err != nil {
 		return nil, err
 	}
-	d.freelistPage = p.num
 	return p, nil
 }
 
@@ -237,7 +384,10 @@ func (d *dal) parseMeta() (*meta, error) {
 	}
 
 	meta := newEmptyMeta()
-	meta.deserialize(p.data)
+	err = meta.deserialize(p.data)
+	if err != nil {
+		return nil, err
+	}
 	return meta, nil
 }
 
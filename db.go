@@ -1,40 +1,204 @@
-package main
+package customdb
 
 import (
+	"context"
 	"os"
 	"sync"
+	"time"
 )
 
-type Database struct {
+// DB is an open database file. Any number of read transactions or a single write transaction can be open at a time.
+type DB struct {
 	rwlock sync.RWMutex // 1 author - n readers
 	*dal
+
+	// extractors of the indexes, by collection and index name. They're code, so they aren't stored in the file
+	indexFuncs map[string]IndexFunc
+
+	batchMu       sync.Mutex
+	batch         *batch
+	maxBatchSize  int
+	maxBatchDelay time.Duration
+
+	// stops the background sync of DurabilityPeriodic
+	stopSync chan struct{}
+	syncDone chan struct{}
+	syncErr  error
 }
 
-func Open(path string, Params *Params) (*Database, error) {
-	Params.pSize = os.Getpagesize()
-	dal, err := newDal(path, Params)
+// Open opens the database file at path, creating it if it doesn't exist. A nil params opens it with DefaultParams.
+func Open(path string, params *Params) (*DB, error) {
+	if params == nil {
+		params = DefaultParams
+	}
+	// the params of the caller are often DefaultParams, shared by every Open
+	localParams := *params
+	params = &localParams
+	params.pSize = os.Getpagesize()
+	dal, err := newDal(path, params)
 	if err != nil {
+		if params.Logger != nil {
+			params.Logger.Error("can't open database", "path", path, "err", err)
+		}
 		return nil, err
 	}
+	dal.logger.Info("database opened", "path", path, "pages", dal.maxAllowedPage+1,
+		"freePages", len(dal.scrapedPages), "readOnly", dal.readOnly)
+
+	db := &DB{
+		rwlock:        sync.RWMutex{},
+		dal:           dal,
+		indexFuncs:    map[string]IndexFunc{},
+		maxBatchSize:  params.MaxBatchSize,
+		maxBatchDelay: params.MaxBatchDelay,
+	}
+	if db.maxBatchSize == 0 {
+		db.maxBatchSize = defaultMaxBatchSize
+	}
+	if db.maxBatchDelay == 0 {
+		db.maxBatchDelay = defaultMaxBatchDelay
+	}
+
+	if params.Durability == DurabilityPeriodic {
+		interval := params.SyncInterval
+		if interval == 0 {
+			interval = defaultSyncInterval
+		}
+		db.stopSync = make(chan struct{})
+		db.syncDone = make(chan struct{})
+		go db.syncPeriodically(interval)
+	}
+
+	return db, nil
+}
+
+// Close flushes the file unless the durability is DurabilityNone and closes it.
+func (db *DB) Close() error {
+	if db.stopSync != nil {
+		close(db.stopSync)
+		<-db.syncDone
+		db.stopSync = nil
+		if db.syncErr != nil {
+			_ = db.close()
+			return db.syncErr
+		}
+	}
+
+	if db.durability != DurabilityNone {
+		err := db.sync()
+		if err != nil {
+			_ = db.close()
+			return err
+		}
+	}
+	return db.close()
+}
+
+// Sync flushes the committed transactions to the disk, whatever the durability of the database is.
+func (db *DB) Sync() error {
+	return db.sync()
+}
 
-	Database := &Database{
-		sync.RWMutex{},
-		dal,
+func (db *DB) syncPeriodically(interval time.Duration) {
+	defer close(db.syncDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// a failure is returned by Close unless a later tick succeeds
+			db.syncErr = db.sync()
+			if db.syncErr != nil {
+				db.logger.Warn("periodic sync failed", "err", db.syncErr)
+			}
+		case <-db.stopSync:
+			return
+		}
 	}
+}
+
+// TxOptions configures a transaction started by BeginTx.
+type TxOptions struct {
+	// Writable starts a write transaction instead of a read one
+	Writable bool
+}
+
+// ReadTx starts a read transaction. It has to be finished with Commit or Rollback.
+func (db *DB) ReadTx() *Tx {
+	start := time.Now()
+	db.rwlock.RLock()
+	db.stats.lockWaited(start)
+	return newTx(context.Background(), db, false)
+}
 
-	return Database, nil
+// WriteTx starts a write transaction, waiting for the other transactions to finish. It has to be finished with Commit
+// or Rollback.
+func (db *DB) WriteTx() *Tx {
+	start := time.Now()
+	db.rwlock.Lock()
+	db.stats.lockWaited(start)
+	return newTx(context.Background(), db, true)
 }
 
-func (Database *Database) Close() error {
-	return Database.close()
+// BeginTx starts a transaction like ReadTx and WriteTx, but stops waiting for the lock with ctx.Err() once ctx is
+// done. Cursors of the transaction abort their scans with ctx.Err() as well. A nil opts starts a read transaction.
+func (db *DB) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
+	write := opts != nil && opts.Writable
+	if write && db.readOnly {
+		return nil, ErrDatabaseReadOnly
+	}
+	start := time.Now()
+	var err error
+	if write {
+		err = lockContext(ctx, db.rwlock.TryLock, db.rwlock.Lock, db.rwlock.Unlock)
+	} else {
+		err = lockContext(ctx, db.rwlock.TryRLock, db.rwlock.RLock, db.rwlock.RUnlock)
+	}
+	if err != nil {
+		return nil, err
+	}
+	db.stats.lockWaited(start)
+	return newTx(ctx, db, write), nil
+}
+
+// lockContext takes the lock unless ctx is done first. sync.RWMutex can't stop waiting, so the lock is taken in a
+// goroutine which releases it again if nobody waits for it anymore.
+func lockContext(ctx context.Context, tryLock func() bool, lock func(), unlock func()) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if tryLock() {
+		return nil
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			unlock()
+		}()
+		return ctx.Err()
+	}
 }
 
-func (Database *Database) ReadTx() *tx {
-	Database.rwlock.RLock()
-	return newTx(Database, false)
+// Update runs fn in a write transaction. The transaction is committed if fn returns nil and rolled back if it returns
+// an error or panics. fn must not commit or roll back the transaction itself.
+func (db *DB) Update(fn func(*Tx) error) error {
+	return db.WriteTx().runManaged(fn)
 }
 
-func (Database *Database) WriteTx() *tx {
-	Database.rwlock.Lock()
-	return newTx(Database, true)
+// View runs fn in a read transaction, which is finished when fn returns or panics. fn must not commit or roll back the
+// transaction itself.
+func (db *DB) View(fn func(*Tx) error) error {
+	return db.ReadTx().runManaged(fn)
 }
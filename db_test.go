@@ -0,0 +1,213 @@
+package customdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpen_NilParams(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.maxStored != DefaultParams.MaxStored || db.maxBatchSize != DefaultParams.MaxBatchSize {
+		t.Fatalf("expected the default params, got max stored %v and batch size %d", db.maxStored, db.maxBatchSize)
+	}
+	checkTestDB(t, db)
+}
+
+func TestOpen_ParamsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	params := &Params{MinStored: testMinPercentage, MaxStored: testMaxPercentage}
+	expected := *params
+
+	// run with -race to catch writes to the shared params
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := Open(filepath.Join(dir, fmt.Sprint(i)), params)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = db.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	if *params != expected {
+		t.Fatalf("Open changed the params from %+v to %+v", expected, *params)
+	}
+}
+
+func TestDB_BeginTxTimeout(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	writer := db.WriteTx()
+
+	for _, opts := range []*TxOptions{nil, {Writable: true}} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		tx, err := db.BeginTx(ctx, opts)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || tx != nil {
+			t.Fatalf("expected BeginTx %+v to time out while the writer holds the lock, got %v", opts, err)
+		}
+	}
+
+	// the locks taken after the timeouts are given back once the writer is done
+	err := writer.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, &TxOptions{Writable: true})
+	if err != nil {
+		t.Fatalf("expected the lock to be free after the timed out BeginTx calls, got %v", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_BeginTxCancelsCursor(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	putTestTreeItems(t, db, 10, smallTestItem)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := collection.Cursor()
+	item, err := cursor.First()
+	if err != nil || item == nil {
+		t.Fatalf("expected the first item before the timeout, got %v, %v", item, err)
+	}
+
+	<-ctx.Done()
+	item, err = cursor.Next()
+	if !errors.Is(err, context.DeadlineExceeded) || item != nil {
+		t.Fatalf("expected Next to stop with the error of the context, got %v, %v", item, err)
+	}
+}
+
+// countTestSyncs replaces syncFile with one that counts the syncs until the test ends
+func countTestSyncs(t *testing.T) *atomic.Int32 {
+	var syncs atomic.Int32
+	syncFile = func(file *os.File) error {
+		syncs.Add(1)
+		return file.Sync()
+	}
+	t.Cleanup(func() {
+		syncFile = (*os.File).Sync
+	})
+	return &syncs
+}
+
+// openTestDurabilityDB opens a database with the durability, creating a collection in it
+func openTestDurabilityDB(t *testing.T, durability Durability, interval time.Duration) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "db"), &Params{
+		MinStored:    testMinPercentage,
+		MaxStored:    testMaxPercentage,
+		Durability:   durability,
+		SyncInterval: interval,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDB_Durability(t *testing.T) {
+	tests := []struct {
+		name       string
+		durability Durability
+		// syncs after a commit and after Close
+		commitSyncs int32
+		closeSyncs  int32
+	}{
+		{name: "full", durability: DurabilityFull, commitSyncs: 1, closeSyncs: 1},
+		// the interval is too long for a tick, only Close syncs
+		{name: "periodic", durability: DurabilityPeriodic, commitSyncs: 0, closeSyncs: 1},
+		{name: "none", durability: DurabilityNone, commitSyncs: 0, closeSyncs: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			syncs := countTestSyncs(t)
+			db := openTestDurabilityDB(t, test.durability, time.Hour)
+			syncs.Store(0)
+
+			err := db.Update(func(tx *Tx) error {
+				putTestKeys(t, tx, "1")
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if syncs.Load() != test.commitSyncs {
+				t.Errorf("expected %d syncs after a commit, got %d", test.commitSyncs, syncs.Load())
+			}
+
+			// Close doesn't sync what was already synced
+			err = db.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if syncs.Load() != test.closeSyncs {
+				t.Errorf("expected %d syncs after Close, got %d", test.closeSyncs, syncs.Load())
+			}
+		})
+	}
+}
+
+func TestDB_PeriodicSync(t *testing.T) {
+	syncs := countTestSyncs(t)
+	db := openTestDurabilityDB(t, DurabilityPeriodic, time.Millisecond)
+
+	// the commit is synced by a tick, without Close
+	deadline := time.Now().Add(time.Second)
+	for (syncs.Load() == 0 || db.dirty.Load()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if syncs.Load() == 0 || db.dirty.Load() {
+		t.Fatal("expected the commit to be synced in the background")
+	}
+
+	err := db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-db.syncDone:
+	default:
+		t.Fatal("expected Close to stop the periodic sync")
+	}
+}
@@ -0,0 +1,104 @@
+package customdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDOTKeyLength is the number of characters of a key shown in a node of WriteDOT
+const maxDOTKeyLength = 12
+
+// WriteDOT writes the tree of the collection to w as a Graphviz graph. Every node shows its page, its fill in percents
+// of a page and its keys, truncated. Keys that aren't printable text are shown in hex.
+func (c *Collection) WriteDOT(w io.Writer) error {
+	err := c.tx.readable()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "digraph %q {\n", "collection "+string(c.name))
+	fmt.Fprintln(out, "\tnode [shape=record, fontname=monospace];")
+	if c.rootNodePage != 0 {
+		err = c.writeDOTNode(out, c.rootNodePage)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+func (c *Collection) writeDOTNode(out *bufio.Writer, page pgnum) error {
+	node, err := c.tx.getNode(page)
+	if err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, 2*len(node.items)+1)
+	for i, item := range node.items {
+		if !node.isLeaf() {
+			fields = append(fields, fmt.Sprintf("<c%d>", i))
+		}
+		fields = append(fields, dotEscape(dotKey(item.Key)))
+	}
+	if !node.isLeaf() {
+		fields = append(fields, fmt.Sprintf("<c%d>", len(node.childNodes)-1))
+	}
+	if len(fields) == 0 {
+		fields = append(fields, "empty")
+	}
+
+	fill := float64(node.nodeSize()) / float64(c.tx.db.pSize) * 100
+	fmt.Fprintf(out, "\tp%d [label=\"{page %d \\| %.1f%%|{%s}}\"];\n", page, page, fill, strings.Join(fields, "|"))
+
+	for i, child := range node.childNodes {
+		fmt.Fprintf(out, "\tp%d:c%d -> p%d;\n", page, i, child)
+	}
+	for _, child := range node.childNodes {
+		err = c.writeDOTNode(out, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dotKey returns the key as text truncated to maxDOTKeyLength characters, or in hex if it isn't printable
+func dotKey(key []byte) string {
+	text := utf8.Valid(key)
+	for _, r := range string(key) {
+		if !unicode.IsPrint(r) {
+			text = false
+			break
+		}
+	}
+	s := string(key)
+	if !text {
+		s = "0x" + hex.EncodeToString(key)
+	}
+
+	runes := []rune(s)
+	if len(runes) > maxDOTKeyLength {
+		return string(runes[:maxDOTKeyLength]) + "…"
+	}
+	return s
+}
+
+// dotEscape escapes the characters with a meaning in record labels
+func dotEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '{', '}', '|', '<', '>', '"', '\\', ' ':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package customdb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestDOT returns the graph of the test collection
+func writeTestDOT(t *testing.T, db *DB) string {
+	t.Helper()
+	var out bytes.Buffer
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		return collection.WriteDOT(&out)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestCollection_WriteDOT(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	createTestTree(t, db, twoLevelTestShape)
+
+	// the fill depends on the page size of the machine
+	leafFill := float64(twoLevelTestLeafSize) / float64(db.pSize) * 100
+	branchFill := float64(twoLevelTestBranchSize) / float64(db.pSize) * 100
+	expected := fmt.Sprintf(`digraph "collection test1" {
+	node [shape=record, fontname=monospace];
+	p5 [label="{page 5 \| %.1f%%|{<c0>|0001kkkkkkkk…|<c1>}}"];
+	p5:c0 -> p3;
+	p5:c1 -> p4;
+	p3 [label="{page 3 \| %.1f%%|{0000kkkkkkkk…}}"];
+	p4 [label="{page 4 \| %.1f%%|{0002kkkkkkkk…}}"];
+}
+`, branchFill, leafFill, leafFill)
+
+	got := writeTestDOT(t, db)
+	if got != expected {
+		t.Fatalf("expected the graph\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestCollection_WriteDOTKeys(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.CreateCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{"a b|c", "\x00\x01"} {
+			err = collection.Put([]byte(key), []byte("1"))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := writeTestDOT(t, db)
+	expected := `{0x0001|a\ b\|c}`
+	if !bytes.Contains([]byte(got), []byte(expected)) {
+		t.Fatalf("expected the keys %s in the graph, got\n%s", expected, got)
+	}
+}
@@ -0,0 +1,301 @@
+package customdb
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultImportBatchSize is the number of items an import writes in a transaction if no batch size is given
+const defaultImportBatchSize = 1000
+
+// exportModes are the names of the collection modes in the headers of an export
+var exportModes = map[collectionMode]string{
+	collectionModeDefault:    "default",
+	collectionModeTimeSeries: "time-series",
+}
+
+// exportLine is a line of the JSON Lines format of Export and Import, either the header of a collection, with its mode
+// and the intervals of its rollups, or an item. Keys and values that aren't UTF-8 text are stored in the base64 fields
+// instead.
+type exportLine struct {
+	Collection  string   `json:"collection"`
+	Mode        string   `json:"mode,omitempty"`
+	Rollups     []string `json:"rollups,omitempty"`
+	Key         *string  `json:"key,omitempty"`
+	KeyBase64   *string  `json:"key_base64,omitempty"`
+	Value       *string  `json:"value,omitempty"`
+	ValueBase64 *string  `json:"value_base64,omitempty"`
+}
+
+func (line *exportLine) hasItem() bool {
+	return line.Key != nil || line.KeyBase64 != nil || line.Value != nil || line.ValueBase64 != nil
+}
+
+func encodeExportField(b []byte) (text *string, encoded *string) {
+	s := string(b)
+	if utf8.Valid(b) {
+		return &s, nil
+	}
+	s = base64.StdEncoding.EncodeToString(b)
+	return nil, &s
+}
+
+func decodeExportField(name string, text *string, encoded *string) ([]byte, error) {
+	switch {
+	case text != nil && encoded != nil:
+		return nil, fmt.Errorf("both %s and %s_base64 are set", name, name)
+	case text != nil:
+		return []byte(*text), nil
+	case encoded != nil:
+		return base64.StdEncoding.DecodeString(*encoded)
+	default:
+		return nil, fmt.Errorf("%s is missing", name)
+	}
+}
+
+// Export writes the given collections, or all of them if none is given, to w as JSON Lines: a {"collection", "mode",
+// "rollups"} header per collection, followed by one {"collection", "key", "value"} object per item, in key order. Keys
+// and values that aren't UTF-8 text are written base64 encoded in "key_base64" and "value_base64". Indexes aren't
+// exported, their extractors are code and have to be registered again with CreateIndex. The export is a snapshot of a
+// single read transaction.
+func (db *DB) Export(w io.Writer, collections ...[]byte) error {
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	err := db.View(func(tx *Tx) error {
+		if len(collections) == 0 {
+			var err error
+			collections, err = tx.Collections()
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, name := range collections {
+			collection, err := tx.GetCollection(name)
+			if err != nil {
+				return fmt.Errorf("collection %s: %w", name, err)
+			}
+
+			header := exportLine{Collection: string(name), Mode: exportModes[collection.mode]}
+			for _, interval := range collection.Rollups() {
+				header.Rollups = append(header.Rollups, interval.String())
+			}
+			err = encoder.Encode(header)
+			if err != nil {
+				return err
+			}
+
+			cursor := collection.Cursor()
+			item, err := cursor.First()
+			for ; err == nil && item != nil; item, err = cursor.Next() {
+				line := exportLine{Collection: string(name)}
+				line.Key, line.KeyBase64 = encodeExportField(item.Key)
+				line.Value, line.ValueBase64 = encodeExportField(item.Value)
+				err = encoder.Encode(line)
+				if err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// Import reads the JSON Lines written by Export from r and puts the items in their collections. A collection missing
+// from the database is created with the mode and rollups of its header, or as a default collection for items without
+// one. An existing collection gets the rollups of the header it lacks, and a header with another mode is an error. The
+// items are written in transactions of batchSize items, 1000 if it's not positive, so large imports don't hold the
+// write lock for long. It returns the number of items imported. On an error the batches committed before it stay.
+// Importing into a collection with indexes fails with ErrIndexNotRegistered unless their extractors were registered
+// with CreateIndex since the database was opened.
+func (db *DB) Import(r io.Reader, batchSize int) (int, error) {
+	w := db.newBatchWriter(batchSize)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var line exportLine
+		err := json.Unmarshal(scanner.Bytes(), &line)
+		if err == nil {
+			err = w.importLine(&line)
+		}
+		if err != nil {
+			w.abort()
+			return w.committed, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		w.abort()
+		return w.committed, err
+	}
+	return w.flush()
+}
+
+// importLine creates the collection of a header or puts an item
+func (w *batchWriter) importLine(line *exportLine) error {
+	if line.Collection == "" {
+		return errors.New("collection is missing")
+	}
+	if line.Mode == "" && line.Rollups == nil {
+		key, err := decodeExportField("key", line.Key, line.KeyBase64)
+		if err != nil {
+			return err
+		}
+		value, err := decodeExportField("value", line.Value, line.ValueBase64)
+		if err != nil {
+			return err
+		}
+		return w.put([]byte(line.Collection), key, value)
+	}
+
+	if line.hasItem() {
+		return errors.New("collection header has a key or value")
+	}
+	mode, ok := collectionModeDefault, false
+	for m, name := range exportModes {
+		if name == line.Mode {
+			mode, ok = m, true
+		}
+	}
+	if !ok {
+		return fmt.Errorf("unknown collection mode %q", line.Mode)
+	}
+	rollups := make([]time.Duration, 0, len(line.Rollups))
+	for _, s := range line.Rollups {
+		interval, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("rollup: %w", err)
+		}
+		rollups = append(rollups, interval)
+	}
+	return w.create([]byte(line.Collection), mode, rollups)
+}
+
+// batchWriter puts items in bounded write transactions, committing one every batchSize items
+type batchWriter struct {
+	db        *DB
+	batchSize int
+	tx        *Tx
+	// collections of the open transaction, by name
+	collections map[string]*Collection
+	pending     int
+	committed   int
+}
+
+func (db *DB) newBatchWriter(batchSize int) *batchWriter {
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	return &batchWriter{
+		db:        db,
+		batchSize: batchSize,
+	}
+}
+
+func (w *batchWriter) begin() {
+	if w.tx == nil {
+		w.tx = w.db.WriteTx()
+		w.collections = map[string]*Collection{}
+	}
+}
+
+// create creates a collection with the mode and rollups if it doesn't exist, or adds the rollups it lacks if it does
+func (w *batchWriter) create(name []byte, mode collectionMode, rollups []time.Duration) error {
+	w.begin()
+	err := w.tx.writable()
+	if err != nil {
+		return err
+	}
+
+	collection, err := w.tx.GetCollection(name)
+	if errors.Is(err, ErrCollectionNotFound) {
+		collection, err = w.tx.createNewCollection(name, mode)
+	}
+	if err != nil {
+		return err
+	}
+	if collection.mode != mode {
+		return fmt.Errorf("collection %s already exists with mode %s", name, exportModes[collection.mode])
+	}
+
+	for _, interval := range rollups {
+		if collection.rollup(interval) != nil {
+			continue
+		}
+		err = collection.AddRollup(interval)
+		if err != nil {
+			return err
+		}
+	}
+	w.collections[string(name)] = collection
+	return nil
+}
+
+// put writes an item, creating its collection if it doesn't exist
+func (w *batchWriter) put(name []byte, key []byte, value []byte) error {
+	w.begin()
+
+	collection, ok := w.collections[string(name)]
+	if !ok {
+		var err error
+		collection, err = w.tx.GetCollection(name)
+		if errors.Is(err, ErrCollectionNotFound) {
+			collection, err = w.tx.CreateCollection(name)
+		}
+		if err != nil {
+			return err
+		}
+		w.collections[string(name)] = collection
+	}
+
+	err := collection.Put(key, value)
+	if err != nil {
+		return err
+	}
+	w.pending++
+	if w.pending == w.batchSize {
+		_, err = w.flush()
+	}
+	return err
+}
+
+// flush commits the open transaction and returns the number of items committed so far
+func (w *batchWriter) flush() (int, error) {
+	if w.tx == nil {
+		return w.committed, nil
+	}
+	tx := w.tx
+	w.tx = nil
+	err := tx.Commit()
+	if err != nil {
+		return w.committed, err
+	}
+	w.committed += w.pending
+	w.pending = 0
+	return w.committed, nil
+}
+
+// abort rolls back the items written since the last commit
+func (w *batchWriter) abort() {
+	if w.tx != nil {
+		_ = w.tx.Rollback()
+		w.tx = nil
+		w.pending = 0
+	}
+}
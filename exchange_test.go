@@ -0,0 +1,119 @@
+package customdb
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var emptyTestCollectionName = []byte("empty")
+
+func exportTestDB(t *testing.T, db *DB) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	err := db.Export(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestDB_ExportImport(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := openTestDB(t, filepath.Join(t.TempDir(), "src"))
+	defer src.Close()
+	putTestPoints(t, src, start, 90, time.Minute)
+	err := src.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(emptyTestCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := exportTestDB(t, src)
+
+	dst := openTestDB(t, filepath.Join(t.TempDir(), "dst"))
+	defer dst.Close()
+	count, err := dst.Import(bytes.NewReader(exported), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 90 {
+		t.Errorf("expected 90 items to be imported, got %d", count)
+	}
+	checkTestDB(t, dst)
+
+	err = dst.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		if collection.mode != collectionModeTimeSeries {
+			t.Errorf("expected a time-series collection, got mode %d", collection.mode)
+		}
+		if !reflect.DeepEqual(collection.Rollups(), []time.Duration{time.Minute}) {
+			t.Errorf("expected a rollup of a minute, got %v", collection.Rollups())
+		}
+		_, err = tx.GetCollection(emptyTestCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(exported, exportTestDB(t, dst)) {
+		t.Error("export of the imported database differs from the original one")
+	}
+	expected, got := aggregateTestMinute(t, src, start), aggregateTestMinute(t, dst, start)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected rollup buckets %v, got %v", expected, got)
+	}
+}
+
+func TestDB_ImportExistingCollection(t *testing.T) {
+	header := `{"collection":"test1","mode":"time-series","rollups":["1m0s"]}`
+
+	t.Run("rollups", func(t *testing.T) {
+		db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+		defer db.Close()
+		putTestPoints(t, db, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 10)
+
+		_, err := db.Import(strings.NewReader(header), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.View(func(tx *Tx) error {
+			collection, err := tx.GetCollection(testCollectionName)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(collection.Rollups(), []time.Duration{time.Minute}) {
+				t.Errorf("expected the rollup of the header to be added, got %v", collection.Rollups())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("mode", func(t *testing.T) {
+		db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+		defer db.Close()
+		err := db.Update(func(tx *Tx) error {
+			_, err := tx.CreateCollection(testCollectionName)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Import(strings.NewReader(header), 0)
+		if err == nil || !strings.Contains(err.Error(), "mode default") {
+			t.Fatalf("expected an error about the mode of the collection, got %v", err)
+		}
+	})
+}
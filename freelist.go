@@ -1,6 +1,10 @@
-package main
+package customdb
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
 
 const metaPage = 0
 
@@ -19,7 +23,7 @@ func setFreeList() *freelist {
 	}
 }
 
-func (freelist *freelist) AllocateNewPage() pgnum {
+func (freelist *freelist) allocateNewPage() pgnum {
 	if len(freelist.scrapedPages) != 0 {
 		// Take the last element and remove it from the list
 		pageID := freelist.scrapedPages[len(freelist.scrapedPages)-1]
@@ -34,7 +38,39 @@ func (freelist *freelist) deletePage(page pgnum) {
 	freelist.scrapedPages = append(freelist.scrapedPages, page)
 }
 
-func (freelist *freelist) serialize(buf []byte) []byte {
+// freelistState is a copy of the freelist, taken when a write transaction starts so rolling it back can return the
+// pages it allocated or released
+type freelistState struct {
+	maxAllowedPage pgnum
+	scrapedPages   []pgnum
+}
+
+func (freelist *freelist) snapshot() freelistState {
+	return freelistState{
+		maxAllowedPage: freelist.maxAllowedPage,
+		scrapedPages:   append([]pgnum{}, freelist.scrapedPages...),
+	}
+}
+
+func (freelist *freelist) restore(state freelistState) {
+	freelist.maxAllowedPage = state.maxAllowedPage
+	freelist.scrapedPages = append([]pgnum{}, state.scrapedPages...)
+}
+
+// freelistCapacity is the number of free pages a freelist page of the size can hold
+func freelistCapacity(pSize int) int {
+	return (pSize - 4) / pageNumSize
+}
+
+func (freelist *freelist) serialize(buf []byte) ([]byte, error) {
+	// the last page is stored in two bytes
+	if freelist.maxAllowedPage > math.MaxUint16 {
+		return nil, fileTooLargeErr
+	}
+	if len(freelist.scrapedPages) > freelistCapacity(len(buf)) {
+		return nil, freelistFullErr
+	}
+
 	pos := 0
 
 	binary.LittleEndian.PutUint16(buf[pos:], uint16(freelist.maxAllowedPage))
@@ -49,10 +85,10 @@ func (freelist *freelist) serialize(buf []byte) []byte {
 		pos += pageNumSize
 
 	}
-	return buf
+	return buf, nil
 }
 
-func (freelist *freelist) deserialize(buf []byte) {
+func (freelist *freelist) deserialize(buf []byte) error {
 	pos := 0
 	freelist.maxAllowedPage = pgnum(binary.LittleEndian.Uint16(buf[pos:]))
 	pos += 2
@@ -60,9 +96,13 @@ func (freelist *freelist) deserialize(buf []byte) {
 	// released pages count
 	scrapedPagesCount := int(binary.LittleEndian.Uint16(buf[pos:]))
 	pos += 2
+	if pos+scrapedPagesCount*pageNumSize > len(buf) {
+		return fmt.Errorf("%w: freelist of %d pages doesn't fit its page", ErrCorrupted, scrapedPagesCount)
+	}
 
 	for i := 0; i < scrapedPagesCount; i++ {
 		freelist.scrapedPages = append(freelist.scrapedPages, pgnum(binary.LittleEndian.Uint64(buf[pos:])))
 		pos += pageNumSize
 	}
+	return nil
 }
@@ -1,3 +1,3 @@
-module main
+module github.com/JustEmptyx/customDBn
 
 go 1.20
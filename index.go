@@ -0,0 +1,398 @@
+package customdb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const indexFlagUnique byte = 1
+
+// IndexFunc extracts the indexed value from an item. Items for which it returns nil are not indexed.
+type IndexFunc func(key []byte, value []byte) []byte
+
+// UniqueViolationError is returned when an item has the same value in a unique index as another item. It matches
+// ErrUniqueViolation with errors.Is.
+type UniqueViolationError struct {
+	Index []byte
+	Value []byte
+	// key of the item that already has the value
+	Key []byte
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("%s: index %q, value %q is already used by key %q", ErrUniqueViolation, e.Index, e.Value, e.Key)
+}
+
+func (e *UniqueViolationError) Unwrap() error {
+	return ErrUniqueViolation
+}
+
+// index is a tree of a collection mapping the values extracted from its items to their keys. The keys of the tree are
+// the length of the value, the value and the key of the item, so items with the same value are next to each other.
+// A unique index has a single entry per value, with the key of the item as the value of the entry.
+type index struct {
+	name   []byte
+	unique bool
+	tree   *Collection
+}
+
+func newIndex(owner *Collection, name []byte, unique bool, root pgnum) *index {
+	tree := newEmptyCollection()
+	tree.rootNodePage = root
+	tree.owner = owner
+	return &index{
+		name:   name,
+		unique: unique,
+		tree:   tree,
+	}
+}
+
+func (idx *index) headerSize() int {
+	return 1 + len(idx.name) + indexFlagsSize + pageNumSize
+}
+
+func (idx *index) flags() byte {
+	if idx.unique {
+		return indexFlagUnique
+	}
+	return 0
+}
+
+// collection returns the index tree in the transaction of its owner
+func (idx *index) collection() *Collection {
+	idx.tree.tx = idx.tree.owner.tx
+	return idx.tree
+}
+
+func (idx *index) extractor() (IndexFunc, error) {
+	owner := idx.tree.owner
+	fn, ok := owner.tx.db.indexFuncs[indexFuncKey(owner.name, idx.name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: index %q of collection %q", ErrIndexNotRegistered, idx.name, owner.name)
+	}
+	return fn, nil
+}
+
+func indexFuncKey(collectionName []byte, indexName []byte) string {
+	return string(collectionName) + "\x00" + string(indexName)
+}
+
+func encodeIndexKey(value []byte, key []byte) ([]byte, error) {
+	if 1+len(value)+len(key) > 255 {
+		return nil, indexKeyTooLargeErr
+	}
+
+	b := make([]byte, 1+len(value)+len(key))
+	pos := 0
+	b[pos] = byte(len(value))
+	pos += 1
+	copy(b[pos:], value)
+	pos += len(value)
+	copy(b[pos:], key)
+	return b, nil
+}
+
+func (idx *index) entryKey(value []byte, key []byte) ([]byte, error) {
+	if idx.unique {
+		return encodeIndexKey(value, nil)
+	}
+	return encodeIndexKey(value, key)
+}
+
+// primaryKey returns the key of the item an entry found by the value prefix points to
+func (idx *index) primaryKey(entry *Item, prefix []byte) []byte {
+	if idx.unique {
+		return entry.Value
+	}
+	return entry.Key[len(prefix):]
+}
+
+// conflict returns the key of another item that already has the value in a unique index, nil if there is none
+func (idx *index) conflict(value []byte, key []byte) ([]byte, error) {
+	entryKey, err := encodeIndexKey(value, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := idx.collection().Find(entryKey)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || bytes.Equal(entry.Value, key) {
+		return nil, nil
+	}
+	return entry.Value, nil
+}
+
+func (idx *index) put(value []byte, key []byte) error {
+	entryKey, err := idx.entryKey(value, key)
+	if err != nil {
+		return err
+	}
+	return idx.putEntry(entryKey, key)
+}
+
+func (idx *index) putEntry(entryKey []byte, key []byte) error {
+	entryValue := []byte{}
+	if idx.unique {
+		entryValue = key
+	}
+	return idx.collection().put(entryKey, entryValue)
+}
+
+// CreateIndex creates an index of the values the extractor returns for the items of the collection, filled with the
+// items already in it. The extractor isn't stored in the file, so after opening the database CreateIndex has to be
+// called again for every index before the collection is written to; for an existing index it only registers the
+// extractor.
+func (c *Collection) CreateIndex(name []byte, extractor IndexFunc) error {
+	return c.createIndex(name, extractor, false)
+}
+
+// CreateUniqueIndex creates an index like CreateIndex that also rejects items whose value is already used by another
+// item. It fails with a *UniqueViolationError if the items already in the collection violate it.
+func (c *Collection) CreateUniqueIndex(name []byte, extractor IndexFunc) error {
+	return c.createIndex(name, extractor, true)
+}
+
+func (c *Collection) createIndex(name []byte, extractor IndexFunc, unique bool) error {
+	err := c.tx.writable()
+	if err != nil {
+		return err
+	}
+
+	if idx := c.index(name); idx != nil {
+		if idx.unique != unique {
+			return indexExistsErr
+		}
+		c.tx.db.indexFuncs[indexFuncKey(c.name, name)] = extractor
+		return nil
+	}
+
+	idx := newIndex(c, name, unique, 0)
+	if c.headerSize()+idx.headerSize() > maxHeaderSize {
+		return headerTooLargeErr
+	}
+
+	err = c.backfillIndex(idx, extractor)
+	if err != nil {
+		// nothing references the partly filled tree, give its pages back
+		freeErr := idx.collection().freeTree()
+		if freeErr != nil {
+			return freeErr
+		}
+		return err
+	}
+
+	c.indexes = append(c.indexes, idx)
+	c.tx.db.indexFuncs[indexFuncKey(c.name, name)] = extractor
+	return c.persistRoot()
+}
+
+func (c *Collection) index(name []byte) *index {
+	for _, idx := range c.indexes {
+		if bytes.Equal(idx.name, name) {
+			return idx
+		}
+	}
+	return nil
+}
+
+func (c *Collection) backfillIndex(idx *index, extractor IndexFunc) error {
+	cursor := c.Cursor()
+	item, err := cursor.First()
+	for ; err == nil && item != nil; item, err = cursor.Next() {
+		value := extractor(item.Key, item.Value)
+		if value == nil {
+			continue
+		}
+
+		if idx.unique {
+			conflictKey, err := idx.conflict(value, item.Key)
+			if err != nil {
+				return err
+			}
+			if conflictKey != nil {
+				return &UniqueViolationError{Index: idx.name, Value: value, Key: conflictKey}
+			}
+		}
+
+		err = idx.put(value, item.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// freeTrees gives the pages of the collection, its rollups and its indexes back to the freelist. If the freelist page
+// can't hold them along with the pages already free, it fails without freeing any.
+func (c *Collection) freeTrees() error {
+	trees := []*Collection{c}
+	for _, r := range c.rollups {
+		trees = append(trees, r.collection())
+	}
+	for _, idx := range c.indexes {
+		trees = append(trees, idx.collection())
+	}
+
+	pages := make([][]pgnum, len(trees))
+	freed := len(c.tx.db.freelist.scrapedPages) + len(c.tx.pagesToDelete)
+	for i, tree := range trees {
+		var err error
+		pages[i], err = tree.treePages()
+		if err != nil {
+			return err
+		}
+		freed += len(pages[i])
+	}
+	if freed > freelistCapacity(c.tx.db.pSize) {
+		return freelistFullErr
+	}
+
+	for i, tree := range trees {
+		tree.freePages(pages[i])
+	}
+	return nil
+}
+
+// freeTree gives the pages of a tree back to the freelist
+func (c *Collection) freeTree() error {
+	pages, err := c.treePages()
+	if err != nil {
+		return err
+	}
+	c.freePages(pages)
+	return nil
+}
+
+// treePages returns the pages of the nodes of a tree
+func (c *Collection) treePages() ([]pgnum, error) {
+	if c.rootNodePage == 0 {
+		return nil, nil
+	}
+
+	var pages []pgnum
+	unvisited := []pgnum{c.rootNodePage}
+	for len(unvisited) != 0 {
+		node, err := c.tx.getNode(unvisited[len(unvisited)-1])
+		if err != nil {
+			return nil, err
+		}
+		unvisited = append(unvisited[:len(unvisited)-1], node.childNodes...)
+		pages = append(pages, node.pageNum)
+	}
+	return pages, nil
+}
+
+func (c *Collection) freePages(pages []pgnum) {
+	for _, page := range pages {
+		delete(c.tx.dirtyNodes, page)
+		c.tx.pagesToDelete = append(c.tx.pagesToDelete, page)
+	}
+	c.rootNodePage = 0
+}
+
+// indexUpdate is the change of an item to the entries of one index
+type indexUpdate struct {
+	idx *index
+	// entries to remove and to put, nil if there is none
+	oldEntry []byte
+	newEntry []byte
+}
+
+// prepareIndexUpdates returns the changes that replace the index entries of the old item with the ones of the new
+// value, nil if the item is removed. The entries are built and the unique indexes checked before anything is written,
+// so an item no index can take leaves the collection unchanged.
+func (c *Collection) prepareIndexUpdates(key []byte, oldItem *Item, value []byte) ([]indexUpdate, error) {
+	updates := make([]indexUpdate, 0, len(c.indexes))
+	for _, idx := range c.indexes {
+		extractor, err := idx.extractor()
+		if err != nil {
+			return nil, err
+		}
+
+		var oldValue, newValue []byte
+		if oldItem != nil {
+			oldValue = extractor(oldItem.Key, oldItem.Value)
+		}
+		if value != nil {
+			newValue = extractor(key, value)
+		}
+
+		if oldValue != nil && newValue != nil && bytes.Equal(oldValue, newValue) {
+			continue
+		}
+
+		update := indexUpdate{idx: idx}
+		if oldValue != nil {
+			// an entry too large for the index was never written, there is nothing to remove
+			update.oldEntry, _ = idx.entryKey(oldValue, key)
+		}
+		if newValue != nil {
+			update.newEntry, err = idx.entryKey(newValue, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if idx.unique {
+				conflictKey, err := idx.conflict(newValue, key)
+				if err != nil {
+					return nil, err
+				}
+				if conflictKey != nil {
+					return nil, &UniqueViolationError{Index: idx.name, Value: newValue, Key: conflictKey}
+				}
+			}
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+func (c *Collection) applyIndexUpdates(key []byte, updates []indexUpdate) error {
+	for _, update := range updates {
+		if update.oldEntry != nil {
+			err := update.idx.collection().remove(update.oldEntry)
+			if err != nil {
+				return err
+			}
+		}
+		if update.newEntry != nil {
+			err := update.idx.putEntry(update.newEntry, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindByIndex returns the items of the collection whose indexed value equals the given one, in key order
+func (c *Collection) FindByIndex(name []byte, value []byte) ([]*Item, error) {
+	idx := c.index(name)
+	if idx == nil {
+		return nil, indexNotFoundErr
+	}
+
+	prefix, err := encodeIndexKey(value, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Item, 0)
+	cursor := idx.collection().Cursor()
+	entry, err := cursor.Seek(prefix)
+	for ; err == nil && entry != nil && bytes.HasPrefix(entry.Key, prefix); entry, err = cursor.Next() {
+		item, err := c.Find(idx.primaryKey(entry, prefix))
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,338 @@
+package customdb
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	// indexes the first byte of the value
+	testFirstByteIndex = []byte("first-byte")
+	// indexes the whole value, which is too large for an entry if the value is long
+	testValueIndex = []byte("value")
+	// indexes the first three bytes of the value, unique
+	testPrefixIndex = []byte("prefix")
+)
+
+func indexFirstByte(key []byte, value []byte) []byte {
+	if len(value) == 0 {
+		return nil
+	}
+	return value[:1]
+}
+
+func indexValue(key []byte, value []byte) []byte {
+	return value
+}
+
+func indexPrefix(key []byte, value []byte) []byte {
+	if len(value) < 3 {
+		return nil
+	}
+	return value[:3]
+}
+
+// createTestIndexes creates a collection with a first byte index, a value index and a unique prefix index, in this
+// order
+func createTestIndexes(t *testing.T, db *DB) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.CreateCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testFirstByteIndex, indexFirstByte)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testValueIndex, indexValue)
+		if err != nil {
+			return err
+		}
+		return collection.CreateUniqueIndex(testPrefixIndex, indexPrefix)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkTestIndex fails the test unless the entries of the index are exactly the ones of the items of the collection
+func checkTestIndex(t *testing.T, collection *Collection, name []byte, extractor IndexFunc) {
+	t.Helper()
+	idx := collection.index(name)
+	if idx == nil {
+		t.Fatalf("index %s doesn't exist", name)
+	}
+
+	expected := map[string]bool{}
+	cursor := collection.Cursor()
+	item, err := cursor.First()
+	for ; err == nil && item != nil; item, err = cursor.Next() {
+		value := extractor(item.Key, item.Value)
+		if value == nil {
+			continue
+		}
+		entryKey, err := idx.entryKey(value, item.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[string(entryKey)] = true
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := 0
+	cursor = idx.collection().Cursor()
+	entry, err := cursor.First()
+	for ; err == nil && entry != nil; entry, err = cursor.Next() {
+		entries++
+		if !expected[string(entry.Key)] {
+			t.Errorf("index %s has the entry %q of no item", name, entry.Key)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != len(expected) {
+		t.Errorf("index %s has %d entries, expected %d", name, entries, len(expected))
+	}
+}
+
+func checkTestIndexes(t *testing.T, db *DB) {
+	t.Helper()
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		checkTestIndex(t, collection, testFirstByteIndex, indexFirstByte)
+		checkTestIndex(t, collection, testValueIndex, indexValue)
+		checkTestIndex(t, collection, testPrefixIndex, indexPrefix)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndex_PutRemove(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	createTestIndexes(t, db)
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for _, kv := range [][2]string{{"a", "apple"}, {"b", "banana"}, {"c", "avocado"}, {"a", "cherry"}} {
+			err = collection.Put([]byte(kv[0]), []byte(kv[1]))
+			if err != nil {
+				return err
+			}
+		}
+		return collection.Remove([]byte("b"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestIndexes(t, db)
+
+	err = db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		items, err := collection.FindByIndex(testFirstByteIndex, []byte("a"))
+		if err != nil {
+			return err
+		}
+		if len(items) != 1 || !bytes.Equal(items[0].Key, []byte("c")) {
+			t.Errorf("expected only c to start with a, got %v", items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndex_PutTooLargeEntry(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	createTestIndexes(t, db)
+
+	// the value fits an item, but not an entry of the value index
+	large := bytes.Repeat([]byte("x"), 255)
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		err = collection.Put([]byte("a"), []byte("apple"))
+		if err != nil {
+			return err
+		}
+
+		for _, key := range []string{"a", "b"} {
+			err = collection.Put([]byte(key), large)
+			if !errors.Is(err, indexKeyTooLargeErr) {
+				t.Errorf("expected indexKeyTooLargeErr for %s, got %v", key, err)
+			}
+		}
+
+		item, err := collection.Find([]byte("a"))
+		if err != nil {
+			return err
+		}
+		if item == nil || !bytes.Equal(item.Value, []byte("apple")) {
+			t.Error("failed put replaced the value of a")
+		}
+		item, err = collection.Find([]byte("b"))
+		if err != nil {
+			return err
+		}
+		if item != nil {
+			t.Error("failed put wrote b")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestIndexes(t, db)
+}
+
+func TestIndex_PutUniqueViolation(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	createTestIndexes(t, db)
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for _, kv := range [][2]string{{"a", "apple"}, {"b", "banana"}} {
+			err = collection.Put([]byte(kv[0]), []byte(kv[1]))
+			if err != nil {
+				return err
+			}
+		}
+
+		// the indexes before the unique one would change for b
+		err = collection.Put([]byte("b"), []byte("applesauce"))
+		var violation *UniqueViolationError
+		if !errors.As(err, &violation) || !bytes.Equal(violation.Key, []byte("a")) {
+			t.Errorf("expected a violation of a, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestIndexes(t, db)
+}
+
+func TestIndex_RemoveWithoutExtractor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	createTestIndexes(t, db)
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		return collection.Put([]byte("a"), []byte("apple"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last index isn't registered again
+	db = openTestDB(t, path)
+	defer db.Close()
+	err = db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testFirstByteIndex, indexFirstByte)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testValueIndex, indexValue)
+		if err != nil {
+			return err
+		}
+
+		err = collection.Remove([]byte("a"))
+		if !errors.Is(err, ErrIndexNotRegistered) {
+			t.Errorf("expected ErrIndexNotRegistered, got %v", err)
+		}
+		err = collection.Put([]byte("a"), []byte("avocado"))
+		if !errors.Is(err, ErrIndexNotRegistered) {
+			t.Errorf("expected ErrIndexNotRegistered, got %v", err)
+		}
+		return collection.CreateUniqueIndex(testPrefixIndex, indexPrefix)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestIndexes(t, db)
+}
+
+func TestIndex_ImportWithoutExtractor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	createTestIndexes(t, db)
+	err := db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := `{"collection":"test1","key":"a","value":"apple"}`
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	count, err := db.Import(strings.NewReader(items), 0)
+	if !errors.Is(err, ErrIndexNotRegistered) ||
+		!strings.Contains(err.Error(), `index "first-byte" of collection "test1"`) {
+		t.Errorf("expected ErrIndexNotRegistered naming the index, got %v", err)
+	}
+	if count != 0 || len(readTestCollection(t, db)) != 0 {
+		t.Errorf("expected nothing to be imported, got %d items", count)
+	}
+
+	// with the extractors registered the entries are kept in sync
+	err = db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testFirstByteIndex, indexFirstByte)
+		if err != nil {
+			return err
+		}
+		err = collection.CreateIndex(testValueIndex, indexValue)
+		if err != nil {
+			return err
+		}
+		return collection.CreateUniqueIndex(testPrefixIndex, indexPrefix)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err = db.Import(strings.NewReader(items), 0)
+	if err != nil || count != 1 {
+		t.Fatalf("expected the item to be imported, got %d, %v", count, err)
+	}
+	checkTestIndexes(t, db)
+}
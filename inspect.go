@@ -0,0 +1,143 @@
+package customdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PageType is what a page of the file holds. Keys and values are at most 255 bytes and always fit a node, so the
+// format has no overflow pages.
+type PageType string
+
+const (
+	PageMeta     PageType = "meta"
+	PageFreelist PageType = "freelist"
+	// PageBranch is an internal node, its items are stored between the child pages
+	PageBranch PageType = "branch"
+	PageLeaf   PageType = "leaf"
+	// PageFree is a page released to the freelist, its content is stale
+	PageFree       PageType = "free"
+	PageUnreadable PageType = "unreadable"
+)
+
+// PageInfo describes a page of the file.
+type PageInfo struct {
+	Num  uint64
+	Type PageType
+	// Tree is the tree the node belongs to, like "collection users", empty for pages that aren't reachable
+	Tree  string
+	Items int
+	// Size is the number of bytes the node takes in its page
+	Size int
+}
+
+// PageDump is a page of the file, decoded into its fields.
+type PageDump struct {
+	PageInfo
+	Data []byte
+
+	// Root and FreelistPage are set for the meta page
+	Root         uint64
+	FreelistPage uint64
+	// LastPage and FreePages are set for the freelist page
+	LastPage  uint64
+	FreePages []uint64
+	// Items and Children are set for nodes
+	Items    []*Item
+	Children []uint64
+	// Problem tells why an unreadable page couldn't be decoded
+	Problem string
+}
+
+// Pages lists every page of the file with its type.
+func (db *DB) Pages() ([]PageInfo, error) {
+	tx := db.ReadTx()
+	defer tx.Rollback()
+
+	owners, free := tx.pageOwners()
+	pages := make([]PageInfo, 0, int(db.maxAllowedPage)+1)
+	for page := pgnum(0); page <= db.maxAllowedPage; page++ {
+		dump := tx.dumpPage(page, owners, free)
+		pages = append(pages, dump.PageInfo)
+	}
+	return pages, nil
+}
+
+// Page decodes the page with the given number.
+func (db *DB) Page(num uint64) (*PageDump, error) {
+	tx := db.ReadTx()
+	defer tx.Rollback()
+
+	if pgnum(num) > db.maxAllowedPage {
+		return nil, fmt.Errorf("page %d is past the last page %d", num, db.maxAllowedPage)
+	}
+	owners, free := tx.pageOwners()
+	return tx.dumpPage(pgnum(num), owners, free), nil
+}
+
+// pageOwners returns the tree every reachable page belongs to and the free pages
+func (tx *Tx) pageOwners() (map[pgnum]string, map[pgnum]bool) {
+	c := &checker{
+		tx:     tx,
+		owners: map[pgnum]string{},
+	}
+	c.checkTree(tx.root, "root collection", func(page pgnum, item *Item) {
+		c.checkCollection(page, item)
+	})
+
+	free := map[pgnum]bool{}
+	for _, page := range tx.db.scrapedPages {
+		free[page] = true
+	}
+	return c.owners, free
+}
+
+func (tx *Tx) dumpPage(page pgnum, owners map[pgnum]string, free map[pgnum]bool) *PageDump {
+	dump := &PageDump{PageInfo: PageInfo{Num: uint64(page), Tree: owners[page]}}
+	p, err := tx.db.readPage(page)
+	if err != nil {
+		dump.Type = PageUnreadable
+		dump.Problem = err.Error()
+		return dump
+	}
+	dump.Data = p.data
+
+	switch {
+	case page == metaPageNum:
+		dump.Type = PageMeta
+		meta := newEmptyMeta()
+		err = meta.deserialize(p.data)
+		dump.Root = uint64(meta.root)
+		dump.FreelistPage = uint64(meta.freelistPage)
+	case page == tx.db.freelistPage:
+		dump.Type = PageFreelist
+		freelist := setFreeList()
+		err = freelist.deserialize(p.data)
+		dump.LastPage = uint64(freelist.maxAllowedPage)
+		for _, free := range freelist.scrapedPages {
+			dump.FreePages = append(dump.FreePages, uint64(free))
+		}
+		sort.Slice(dump.FreePages, func(i, j int) bool { return dump.FreePages[i] < dump.FreePages[j] })
+	case free[page]:
+		dump.Type = PageFree
+	default:
+		node := newEmptyNode()
+		err = node.deserialize(p.data)
+		dump.Type = PageLeaf
+		if !node.isLeaf() {
+			dump.Type = PageBranch
+		}
+		dump.Items = node.items
+		dump.PageInfo.Items = len(node.items)
+		dump.Size = node.nodeSize()
+		for _, child := range node.childNodes {
+			dump.Children = append(dump.Children, uint64(child))
+		}
+	}
+
+	if err != nil {
+		dump.Type = PageUnreadable
+		dump.Problem = err.Error()
+	}
+	return dump
+}
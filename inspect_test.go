@@ -0,0 +1,119 @@
+package customdb
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// twoLevelTestShape is a branch with an item between two leaves of an item each, built on pages 3, 4 and 5 of a new
+// database: the leaves first, then the branch
+var twoLevelTestShape = testBranch(testLeaf(smallTestItem), testLeaf(smallTestItem))
+
+const (
+	// a leaf of one small item and a branch of one small item and two children
+	twoLevelTestLeafSize   = nodeHeaderSize + itemOverheadSize + 2*smallTestItem + 1
+	twoLevelTestBranchSize = twoLevelTestLeafSize + 2*pageNumSize
+	// the leaf of the root collection with the item of the test collection
+	rootTestLeafSize = 32
+)
+
+func TestDB_Pages(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	createTestTree(t, db, twoLevelTestShape)
+
+	tree := "collection " + string(testCollectionName)
+	expected := []PageInfo{
+		{Num: 0, Type: PageMeta},
+		{Num: 1, Type: PageFreelist},
+		{Num: 2, Type: PageLeaf, Tree: "root collection", Items: 1, Size: rootTestLeafSize},
+		{Num: 3, Type: PageLeaf, Tree: tree, Items: 1, Size: twoLevelTestLeafSize},
+		{Num: 4, Type: PageLeaf, Tree: tree, Items: 1, Size: twoLevelTestLeafSize},
+		{Num: 5, Type: PageBranch, Tree: tree, Items: 1, Size: twoLevelTestBranchSize},
+	}
+	pages, err := db.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("expected pages %+v, got %+v", expected, pages)
+	}
+
+	err = db.Update(func(tx *Tx) error {
+		return tx.DeleteCollection(testCollectionName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []PageInfo{
+		{Num: 0, Type: PageMeta},
+		{Num: 1, Type: PageFreelist},
+		{Num: 2, Type: PageLeaf, Tree: "root collection", Size: nodeHeaderSize + 1},
+		{Num: 3, Type: PageFree},
+		{Num: 4, Type: PageFree},
+		{Num: 5, Type: PageFree},
+	}
+	pages, err = db.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("expected pages %+v after deleting the collection, got %+v", expected, pages)
+	}
+}
+
+func TestDB_Page(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	keys := createTestTree(t, db, twoLevelTestShape)
+
+	meta, err := db.Page(uint64(metaPageNum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Type != PageMeta || meta.Root != 2 || meta.FreelistPage != 1 || len(meta.Data) != db.pSize {
+		t.Errorf("expected the meta page with root 2 and freelist 1, got %+v", meta.PageInfo)
+	}
+
+	branch, err := db.Page(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Type != PageBranch || !reflect.DeepEqual(branch.Children, []uint64{3, 4}) || len(branch.Items) != 1 ||
+		!bytes.Equal(branch.Items[0].Key, keys[1]) {
+		t.Errorf("expected the branch with the middle key between pages 3 and 4, got %+v", branch)
+	}
+
+	_, err = db.Page(6)
+	if err == nil {
+		t.Error("expected an error for a page past the last one")
+	}
+
+	err = db.Update(func(tx *Tx) error {
+		return tx.DeleteCollection(testCollectionName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	freelist, err := db.Page(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freelist.Type != PageFreelist || freelist.LastPage != 5 ||
+		!reflect.DeepEqual(freelist.FreePages, []uint64{3, 4, 5}) {
+		t.Errorf("expected the freelist with pages 3 to 5 free, got %+v", freelist)
+	}
+
+	damageTestPage(t, db, path, 2)
+	db = openTestDB(t, path)
+	defer db.Close()
+	damaged, err := db.Page(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if damaged.Type != PageUnreadable || damaged.Problem == "" {
+		t.Errorf("expected the damaged page to be unreadable with its problem, got %+v", damaged)
+	}
+}
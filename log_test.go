@@ -0,0 +1,80 @@
+package customdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordingLogger keeps the events logged at or above a level as "level message" and their args by key
+type recordingLogger struct {
+	debug  bool
+	events []string
+	args   []map[string]any
+}
+
+func (l *recordingLogger) record(level string, msg string, args []any) {
+	values := map[string]any{}
+	for i := 0; i+1 < len(args); i += 2 {
+		values[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.events = append(l.events, level+" "+msg)
+	l.args = append(l.args, values)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	if l.debug {
+		l.record("DEBUG", msg, args)
+	}
+}
+
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record("INFO", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record("WARN", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record("ERROR", msg, args) }
+
+func TestDB_Logger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	logger := &recordingLogger{}
+	db, err := Open(path, &Params{MinStored: testMinPercentage, MaxStored: testMaxPercentage, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the events of opening the file aren't debug ones, record the ones of the transactions as well from now on
+	logger.debug = true
+	err = db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.WriteTx()
+	putTestKeys(t, tx, "1", "2")
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"INFO database file created",
+		"INFO database opened",
+		"DEBUG transaction committed",
+		"DEBUG transaction rolled back",
+	}
+	if !reflect.DeepEqual(logger.events, expected) {
+		t.Fatalf("expected events %q, got %q", expected, logger.events)
+	}
+	if logger.args[1]["path"] != path {
+		t.Errorf("expected the path of the opened database, got %v", logger.args[1])
+	}
+	// the commit writes the new root of the collection and the root collection
+	if logger.args[2]["writtenPages"] != 2 {
+		t.Errorf("expected the commit to write 2 pages, got %v", logger.args[2])
+	}
+	if logger.args[3]["discardedPages"] != 1 {
+		t.Errorf("expected the rollback to discard the page of the collection, got %v", logger.args[3])
+	}
+}
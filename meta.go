@@ -1,6 +1,9 @@
-package main
+package customdb
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 const (
 	magicNumber uint32 = 0xABCD1234
@@ -31,13 +34,13 @@ func (m *meta) serialize(buf []byte) {
 	pos += pageNumSize
 }
 
-func (m *meta) deserialize(buf []byte) {
+func (m *meta) deserialize(buf []byte) error {
 	pos := 0
 	magicNumberRes := binary.LittleEndian.Uint32(buf[pos:])
 	pos += magicNumberSize
 
 	if magicNumberRes != magicNumber {
-		panic("Something goes wrong: wrong db magic number or file")
+		return fmt.Errorf("%w: wrong magic number %#x in meta page", ErrCorrupted, magicNumberRes)
 	}
 
 	m.root = pgnum(binary.LittleEndian.Uint64(buf[pos:]))
@@ -45,4 +48,5 @@ func (m *meta) deserialize(buf []byte) {
 
 	m.freelistPage = pgnum(binary.LittleEndian.Uint64(buf[pos:]))
 	pos += pageNumSize
+	return nil
 }
@@ -0,0 +1,88 @@
+// Package metrics exposes the statistics of a database in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type metricType string
+
+const (
+	counter metricType = "counter"
+	gauge   metricType = "gauge"
+)
+
+type metric struct {
+	name  string
+	help  string
+	typ   metricType
+	value func(stats customdb.Stats) float64
+}
+
+var metrics = []metric{
+	{"customdb_pages", "Pages of the database file, including the meta and freelist pages.", gauge,
+		func(s customdb.Stats) float64 { return float64(s.Pages) }},
+	{"customdb_free_pages", "Pages released to the freelist.", gauge,
+		func(s customdb.Stats) float64 { return float64(s.FreePages) }},
+	{"customdb_file_size_bytes", "Size of the database file.", gauge,
+		func(s customdb.Stats) float64 { return float64(s.FileSize) }},
+	{"customdb_read_txs_total", "Read transactions started.", counter,
+		func(s customdb.Stats) float64 { return float64(s.ReadTxs) }},
+	{"customdb_write_txs_total", "Write transactions started.", counter,
+		func(s customdb.Stats) float64 { return float64(s.WriteTxs) }},
+	{"customdb_open_read_txs", "Read transactions not finished yet.", gauge,
+		func(s customdb.Stats) float64 { return float64(s.OpenReadTxs) }},
+	{"customdb_open_write_txs", "Write transactions not finished yet.", gauge,
+		func(s customdb.Stats) float64 { return float64(s.OpenWriteTxs) }},
+	{"customdb_commits_total", "Write transactions committed.", counter,
+		func(s customdb.Stats) float64 { return float64(s.Commits) }},
+	{"customdb_rollbacks_total", "Write transactions rolled back.", counter,
+		func(s customdb.Stats) float64 { return float64(s.Rollbacks) }},
+	{"customdb_node_hits_total", "Nodes found among the changes of their transaction.", counter,
+		func(s customdb.Stats) float64 { return float64(s.NodeHits) }},
+	{"customdb_node_reads_total", "Nodes read from the database file.", counter,
+		func(s customdb.Stats) float64 { return float64(s.NodeReads) }},
+	{"customdb_splits_total", "Node splits.", counter,
+		func(s customdb.Stats) float64 { return float64(s.Splits) }},
+	{"customdb_merges_total", "Node merges.", counter,
+		func(s customdb.Stats) float64 { return float64(s.Merges) }},
+	{"customdb_page_reads_total", "Pages read from the database file.", counter,
+		func(s customdb.Stats) float64 { return float64(s.PageReads) }},
+	{"customdb_page_writes_total", "Pages written to the database file.", counter,
+		func(s customdb.Stats) float64 { return float64(s.PageWrites) }},
+	{"customdb_lock_waits_total", "Transactions that took the lock of the database.", counter,
+		func(s customdb.Stats) float64 { return float64(s.LockWaits) }},
+	{"customdb_lock_wait_seconds_total", "Time transactions spent waiting for the lock of the database.", counter,
+		func(s customdb.Stats) float64 { return s.LockWaitTime.Seconds() }},
+}
+
+// Handler returns a handler serving the statistics of db to a Prometheus scrape.
+func Handler(db *customdb.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats, err := db.Stats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_ = Write(w, stats)
+	})
+}
+
+// Write writes stats in the Prometheus text exposition format.
+func Write(w io.Writer, stats customdb.Stats) error {
+	for _, m := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", m.name, m.help, m.name, m.typ, m.name,
+			m.value(stats))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
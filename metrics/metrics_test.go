@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	customdb "github.com/JustEmptyx/customDBn"
+)
+
+// expectTestLines fails the test unless every line is in the output
+func expectTestLines(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, "\n"+line+"\n") && !strings.HasPrefix(out, line+"\n") {
+			t.Errorf("expected the line %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var out bytes.Buffer
+	err := Write(&out, customdb.Stats{
+		Pages:        11,
+		FreePages:    3,
+		FileSize:     45056,
+		Commits:      7,
+		LockWaitTime: 1500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectTestLines(t, out.String(),
+		"# HELP customdb_pages Pages of the database file, including the meta and freelist pages.",
+		"# TYPE customdb_pages gauge",
+		"customdb_pages 11",
+		"customdb_free_pages 3",
+		"customdb_file_size_bytes 45056",
+		"# TYPE customdb_commits_total counter",
+		"customdb_commits_total 7",
+		"customdb_rollbacks_total 0",
+		"customdb_lock_wait_seconds_total 1.5",
+	)
+	count := strings.Count(out.String(), "# TYPE ")
+	if count != len(metrics) {
+		t.Errorf("expected %d metrics, got %d", len(metrics), count)
+	}
+}
+
+func getTestMetrics(t *testing.T, url string) (int, http.Header, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	db, err := customdb.Open(filepath.Join(t.TempDir(), "db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *customdb.Tx) error {
+		_, err := tx.CreateCollection([]byte("test"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(Handler(db))
+	defer server.Close()
+
+	status, header, body := getTestMetrics(t, server.URL)
+	if status != http.StatusOK || header.Get("Content-Type") != contentType {
+		t.Fatalf("expected metrics of type %s, got status %d and type %s", contentType, status,
+			header.Get("Content-Type"))
+	}
+	expectTestLines(t, body,
+		"customdb_write_txs_total 1",
+		"customdb_commits_total 1",
+		"customdb_open_write_txs 0",
+	)
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _, body = getTestMetrics(t, server.URL)
+	if status != http.StatusInternalServerError || !strings.Contains(body, customdb.ErrDatabaseClosed.Error()) {
+		t.Errorf("expected an error for the closed database, got status %d: %s", status, body)
+	}
+}
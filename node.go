@@ -1,4 +1,4 @@
-package main
+package customdb
 
 import (
 	"bytes"
@@ -7,33 +7,33 @@ import (
 )
 
 type Item struct {
-	key   []byte
-	value []byte
+	Key   []byte
+	Value []byte
 }
 
-type CustomItem struct {
+type customItem struct {
 	key    []byte
 	value  []byte
 	tstart []byte
 	tend   []byte
 }
 
-type Node struct {
+type node struct {
 	// associated transaction
-	tx *tx
+	tx *Tx
 
 	pageNum    pgnum
 	items      []*Item
 	childNodes []pgnum
 }
 
-func NewEmptyNode() *Node {
-	return &Node{}
+func newEmptyNode() *node {
+	return &node{}
 }
 
-// NewNodeForSerialization creates a new node only with the properties that are relevant when saving to the disk
-func NewNodeForSerialization(items []*Item, childNodes []pgnum) *Node {
-	return &Node{
+// newNodeForSerialization creates a new node only with the properties that are relevant when saving to the disk
+func newNodeForSerialization(items []*Item, childNodes []pgnum) *node {
+	return &node{
 		items:      items,
 		childNodes: childNodes,
 	}
@@ -41,13 +41,13 @@ func NewNodeForSerialization(items []*Item, childNodes []pgnum) *Node {
 
 func newItem(key []byte, value []byte) *Item {
 	return &Item{
-		key:   key,
-		value: value,
+		Key:   key,
+		Value: value,
 	}
 }
 
-func newCustomItem(key []byte, value []byte, tstart []byte, tend []byte) *CustomItem {
-	return &CustomItem{
+func newCustomItem(key []byte, value []byte, tstart []byte, tend []byte) *customItem {
+	return &customItem{
 		key:    key,
 		value:  value,
 		tstart: tstart,
@@ -55,7 +55,7 @@ func newCustomItem(key []byte, value []byte, tstart []byte, tend []byte) *Custom
 	}
 }
 
-func isLast(index int, parentNode *Node) bool {
+func isLast(index int, parentNode *node) bool {
 	return index == len(parentNode.items)
 }
 
@@ -63,30 +63,30 @@ func isFirst(index int) bool {
 	return index == 0
 }
 
-func (n *Node) isLeaf() bool {
+func (n *node) isLeaf() bool {
 	return len(n.childNodes) == 0
 }
 
-func (n *Node) createNode(node *Node) *Node {
+func (n *node) createNode(node *node) *node {
 	return n.tx.createNode(node)
 }
 
-func (n *Node) createNodes(nodes ...*Node) {
+func (n *node) createNodes(nodes ...*node) {
 	for _, node := range nodes {
 		n.createNode(node)
 	}
 }
 
-func (n *Node) getNode(pageNum pgnum) (*Node, error) {
+func (n *node) getNode(pageNum pgnum) (*node, error) {
 	return n.tx.getNode(pageNum)
 }
 
-func (n *Node) isUpperBoundReached() bool {
-	return n.tx.Database.isUpperBoundReached(n)
+func (n *node) isUpperBoundReached() bool {
+	return n.tx.db.isUpperBoundReached(n)
 }
 
-func (n *Node) canSpareAnElement() bool {
-	splitIndex := n.tx.Database.findBalanceIndex(n)
+func (n *node) canSpareAnElement() bool {
+	splitIndex := n.tx.db.findBalanceIndex(n)
 	if splitIndex == -1 {
 		return false
 	}
@@ -94,11 +94,11 @@ func (n *Node) canSpareAnElement() bool {
 }
 
 // isLowerBoundReached checks if the node size is smaller than the size of a page.
-func (n *Node) isLowerBoundReached() bool {
-	return n.tx.Database.isLowerBoundReached(n)
+func (n *node) isLowerBoundReached() bool {
+	return n.tx.db.isLowerBoundReached(n)
 }
 
-func (n *Node) serialize(buf []byte) []byte {
+func (n *node) serialize(buf []byte) []byte {
 	leftPos := 0
 	rightPos := len(buf) - 1
 
@@ -125,8 +125,8 @@ func (n *Node) serialize(buf []byte) []byte {
 			leftPos += pageNumSize
 		}
 
-		klen := len(item.key)
-		vlen := len(item.value)
+		klen := len(item.Key)
+		vlen := len(item.Value)
 
 		// offset
 		offset := rightPos - klen - vlen - 2
@@ -134,13 +134,13 @@ func (n *Node) serialize(buf []byte) []byte {
 		leftPos += 2
 
 		rightPos -= vlen
-		copy(buf[rightPos:], item.value)
+		copy(buf[rightPos:], item.Value)
 
 		rightPos -= 1
 		buf[rightPos] = byte(vlen)
 
 		rightPos -= klen
-		copy(buf[rightPos:], item.key)
+		copy(buf[rightPos:], item.Key)
 
 		rightPos -= 1
 		buf[rightPos] = byte(klen)
@@ -154,34 +154,55 @@ func (n *Node) serialize(buf []byte) []byte {
 	return buf
 }
 
-func (n *Node) deserialize(buf []byte) {
+func (n *node) deserialize(buf []byte) error {
 	leftPos := 0
+	if len(buf) < nodeHeaderSize {
+		return fmt.Errorf("%w: node page is too small", ErrCorrupted)
+	}
 
 	isLeaf := uint16(buf[0])
+	if isLeaf > 1 {
+		return fmt.Errorf("%w: unknown node type %d", ErrCorrupted, isLeaf)
+	}
 
 	itemsCount := int(binary.LittleEndian.Uint16(buf[1:3]))
 	leftPos += 3
 
 	for i := 0; i < itemsCount; i++ {
 		if isLeaf == 0 { // False
+			if leftPos+pageNumSize > len(buf) {
+				return fmt.Errorf("%w: child %d is out of the page", ErrCorrupted, i)
+			}
 			pageNum := binary.LittleEndian.Uint64(buf[leftPos:])
 			leftPos += pageNumSize
 
 			n.childNodes = append(n.childNodes, pgnum(pageNum))
 		}
 
-		offset := binary.LittleEndian.Uint16(buf[leftPos:])
+		if leftPos+2 > len(buf) {
+			return fmt.Errorf("%w: offset of item %d is out of the page", ErrCorrupted, i)
+		}
+		offset := int(binary.LittleEndian.Uint16(buf[leftPos:]))
 		leftPos += 2
 
-		klen := uint16(buf[int(offset)])
+		if offset >= len(buf) {
+			return fmt.Errorf("%w: item %d is out of the page", ErrCorrupted, i)
+		}
+		klen := int(buf[offset])
 		offset += 1
 
+		if offset+klen >= len(buf) {
+			return fmt.Errorf("%w: key of item %d is out of the page", ErrCorrupted, i)
+		}
 		key := buf[offset : offset+klen]
 		offset += klen
 
-		vlen := uint16(buf[int(offset)])
+		vlen := int(buf[offset])
 		offset += 1
 
+		if offset+vlen > len(buf) {
+			return fmt.Errorf("%w: value of item %d is out of the page", ErrCorrupted, i)
+		}
 		value := buf[offset : offset+vlen]
 		offset += vlen
 		//fmt.Printf("key is: %s, value is: %s\n", key, value)
@@ -190,20 +211,28 @@ func (n *Node) deserialize(buf []byte) {
 
 	if isLeaf == 0 { // False
 		// Read the last child node
+		if leftPos+pageNumSize > len(buf) {
+			return fmt.Errorf("%w: last child is out of the page", ErrCorrupted)
+		}
 		pageNum := pgnum(binary.LittleEndian.Uint64(buf[leftPos:]))
 		n.childNodes = append(n.childNodes, pageNum)
 	}
+	return nil
 }
 
-func (n *Node) elementSize(i int) int {
-	size := 0
-	size += len(n.items[i].key)
-	size += len(n.items[i].value)
-	size += pageNumSize // 8 is the pgnum size
+// elementSize is the number of bytes serialize writes for the item: its offset, the key and value with their lengths
+// and the child page before it in internal nodes
+func (n *node) elementSize(i int) int {
+	size := itemOverheadSize
+	size += len(n.items[i].Key)
+	size += len(n.items[i].Value)
+	if !n.isLeaf() {
+		size += pageNumSize // 8 is the pgnum size
+	}
 	return size
 }
 
-func (n *Node) nodeSize() int {
+func (n *node) nodeSize() int {
 	size := 0
 	size += nodeHeaderSize
 
@@ -212,12 +241,15 @@ func (n *Node) nodeSize() int {
 	}
 
 	// Add last page
-	size += pageNumSize // 8 is the pgnum size
-	return size
+	if !n.isLeaf() {
+		size += pageNumSize // 8 is the pgnum size
+	}
+	// serialize leaves the last byte of the page unused
+	return size + 1
 }
 
 // findkeyhelper работает неправильно, фикс
-func (n *Node) findKey(key []byte, exact bool) (int, *Node, []int, error) {
+func (n *node) findKey(key []byte, exact bool) (int, *node, []int, error) {
 	ancestorsIndexes := []int{0} // index of root
 	index, node, err := findKeyHelper(n, key, exact, &ancestorsIndexes)
 	if err != nil {
@@ -226,7 +258,7 @@ func (n *Node) findKey(key []byte, exact bool) (int, *Node, []int, error) {
 	return index, node, ancestorsIndexes, nil
 }
 
-func findKeyHelper(node *Node, key []byte, exact bool, ancestorsIndexes *[]int) (int, *Node, error) {
+func findKeyHelper(node *node, key []byte, exact bool, ancestorsIndexes *[]int) (int, *node, error) {
 	wasFound, index := node.findKeyInNode(key)
 	if wasFound {
 		return index, node, nil
@@ -247,10 +279,9 @@ func findKeyHelper(node *Node, key []byte, exact bool, ancestorsIndexes *[]int)
 	return findKeyHelper(nextChild, key, exact, ancestorsIndexes)
 }
 
-func (n *Node) findKeyInNode(key []byte) (bool, int) {
+func (n *node) findKeyInNode(key []byte) (bool, int) {
 	for i, existingItem := range n.items {
-		fmt.Printf("%s , %s\n", existingItem.key, key)
-		res := bytes.Compare(existingItem.key, key)
+		res := bytes.Compare(existingItem.Key, key)
 		if res == 0 { // Keys match
 			return true, i
 		}
@@ -263,50 +294,7 @@ func (n *Node) findKeyInNode(key []byte) (bool, int) {
 	return false, len(n.items)
 }
 
-func (n *Node) findAllKeys(key []byte, exact bool) ([][]int, []Node) {
-	ancestorsIndexes := [][]int{{0}} // index of root
-	var nodes []Node
-	findAllKeysHelper(n, key, &ancestorsIndexes, &nodes)
-	print("All keys found")
-
-	return ancestorsIndexes, nodes
-}
-
-func findAllKeysHelper(node *Node, key []byte, ancestorsIndexes *[][]int, nodes *[]Node) {
-	for i := 0; i < len(node.childNodes); i++ {
-		if !node.isLeaf() {
-			nextChild, _ := node.getNode(node.childNodes[i])
-			findAllKeysHelper(nextChild, key, ancestorsIndexes, nodes)
-		}
-		isFound, indexes := node.findAllKeysInNode(key)
-		if isFound {
-			*ancestorsIndexes = append(*ancestorsIndexes, indexes)
-			*nodes = append(*nodes, *node)
-		}
-	}
-
-	if !node.isLeaf() {
-		nextChild, _ := node.getNode(node.childNodes[len(node.childNodes)])
-		findAllKeysHelper(nextChild, key, ancestorsIndexes, nodes)
-	}
-}
-
-func (n *Node) findAllKeysInNode(key []byte) (bool, []int) {
-	var arr []int
-	for i, existingItem := range n.items {
-		temp := existingItem.key[11:]
-		res := bytes.Compare(temp, key)
-		if res == 0 { // Keys match
-			arr = append(arr, i)
-		}
-	}
-	if len(arr) != 0 {
-		return true, arr
-	}
-	return false, arr
-}
-
-func (n *Node) addItem(item *Item, insertionIndex int) int {
+func (n *node) addItem(item *Item, insertionIndex int) int {
 	if len(n.items) == insertionIndex { // nil or empty slice or after last element
 		n.items = append(n.items, item)
 		return insertionIndex
@@ -319,18 +307,29 @@ func (n *Node) addItem(item *Item, insertionIndex int) int {
 
 // split on position
 // used on upper/lower bound
-func (n *Node) split(nodeToSplit *Node, nodeToSplitIndex int) {
+func (n *node) split(nodeToSplit *node, nodeToSplitIndex int) {
+	splitIndex := nodeToSplit.tx.db.findBalanceIndex(nodeToSplit)
+	n.splitAt(nodeToSplit, nodeToSplitIndex, splitIndex)
+}
 
-	splitIndex := nodeToSplit.tx.Database.findBalanceIndex(nodeToSplit)
+// splitAtEnd splits the right-most node of an append-only tree, keeping the left node full instead of half empty
+func (n *node) splitAtEnd(nodeToSplit *node, nodeToSplitIndex int) {
+	splitIndex := nodeToSplit.tx.db.findAppendSplitIndex(nodeToSplit)
+	n.splitAt(nodeToSplit, nodeToSplitIndex, splitIndex)
+}
 
+func (n *node) splitAt(nodeToSplit *node, nodeToSplitIndex int, splitIndex int) {
 	middleItem := nodeToSplit.items[splitIndex]
-	var newNode *Node
+	var newNode *node
 
+	// the new node gets its own copy, appending to the left node must not overwrite its items
+	newItems := append([]*Item{}, nodeToSplit.items[splitIndex+1:]...)
 	if nodeToSplit.isLeaf() {
-		newNode = n.createNode(n.tx.newNode(nodeToSplit.items[splitIndex+1:], []pgnum{}))
+		newNode = n.createNode(n.tx.newNode(newItems, []pgnum{}))
 		nodeToSplit.items = nodeToSplit.items[:splitIndex]
 	} else {
-		newNode = n.createNode(n.tx.newNode(nodeToSplit.items[splitIndex+1:], nodeToSplit.childNodes[splitIndex+1:]))
+		newChildNodes := append([]pgnum{}, nodeToSplit.childNodes[splitIndex+1:]...)
+		newNode = n.createNode(n.tx.newNode(newItems, newChildNodes))
 		nodeToSplit.items = nodeToSplit.items[:splitIndex]
 		nodeToSplit.childNodes = nodeToSplit.childNodes[:splitIndex+1]
 	}
@@ -343,10 +342,13 @@ func (n *Node) split(nodeToSplit *Node, nodeToSplitIndex int) {
 	}
 
 	n.createNodes(n, nodeToSplit)
+	n.tx.db.stats.splits.Add(1)
+	n.tx.db.logger.Debug("node split", "page", nodeToSplit.pageNum, "newPage", newNode.pageNum,
+		"leftItems", len(nodeToSplit.items), "rightItems", len(newNode.items))
 }
 
 // rebalance on remove
-func (n *Node) rebalanceRemove(unbalancedNode *Node, unbalancedNodeIndex int) error {
+func (n *node) rebalanceRemove(unbalancedNode *node, unbalancedNodeIndex int) error {
 	pNode := n
 
 	// Right -> rotate right // BRotateRight
@@ -387,12 +389,12 @@ func (n *Node) rebalanceRemove(unbalancedNode *Node, unbalancedNodeIndex int) er
 	return pNode.merge(unbalancedNode, unbalancedNodeIndex)
 }
 
-func (n *Node) removeItemFromLeaf(index int) {
+func (n *node) removeItemFromLeaf(index int) {
 	n.items = append(n.items[:index], n.items[index+1:]...)
 	n.createNode(n)
 }
 
-func (n *Node) removeItemFromInternal(index int) ([]int, error) {
+func (n *node) removeItemFromInternal(index int) ([]int, error) {
 	//remove largest from left
 
 	affectedNodes := make([]int, 0)
@@ -404,7 +406,7 @@ func (n *Node) removeItemFromInternal(index int) ([]int, error) {
 	}
 
 	for !aNode.isLeaf() {
-		traversingIndex := len(n.childNodes) - 1
+		traversingIndex := len(aNode.childNodes) - 1
 		aNode, err = aNode.getNode(aNode.childNodes[traversingIndex])
 		if err != nil {
 			return nil, err
@@ -419,7 +421,7 @@ func (n *Node) removeItemFromInternal(index int) ([]int, error) {
 	return affectedNodes, nil
 }
 
-func rotateRight(aNode, pNode, bNode *Node, bNodeIndex int) {
+func rotateRight(aNode, pNode, bNode *node, bNodeIndex int) {
 
 	aNodeItem := aNode.items[len(aNode.items)-1]
 	aNode.items = aNode.items[:len(aNode.items)-1]
@@ -442,7 +444,7 @@ func rotateRight(aNode, pNode, bNode *Node, bNodeIndex int) {
 	}
 }
 
-func rotateLeft(aNode, pNode, bNode *Node, bNodeIndex int) {
+func rotateLeft(aNode, pNode, bNode *node, bNodeIndex int) {
 
 	bNodeItem := bNode.items[0]
 	bNode.items = bNode.items[1:]
@@ -465,7 +467,7 @@ func rotateLeft(aNode, pNode, bNode *Node, bNodeIndex int) {
 	}
 }
 
-func (n *Node) merge(bNode *Node, bNodeIndex int) error {
+func (n *node) merge(bNode *node, bNodeIndex int) error {
 	//simple btree merge
 	aNode, err := n.getNode(n.childNodes[bNodeIndex-1])
 	if err != nil {
@@ -483,5 +485,12 @@ func (n *Node) merge(bNode *Node, bNodeIndex int) error {
 	}
 	n.createNodes(aNode, n)
 	n.tx.deleteNode(bNode)
+	n.tx.db.stats.merges.Add(1)
+	n.tx.db.logger.Debug("nodes merged", "page", aNode.pageNum, "mergedPage", bNode.pageNum, "items", len(aNode.items))
+
+	// a node below the lower bound and a sibling that can't spare an element may not fit a page together
+	if aNode.isUpperBoundReached() {
+		n.split(aNode, bNodeIndex-1)
+	}
 	return nil
 }
@@ -0,0 +1,88 @@
+package customdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// serializeTestNode serializes the node into a buffer of nodeSize bytes and decodes it again
+func serializeTestNode(n *node) (decoded *node, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("serialize panicked: %v", p)
+		}
+	}()
+
+	buf := n.serialize(make([]byte, n.nodeSize()))
+	decoded = newEmptyNode()
+	err = decoded.deserialize(buf)
+	return decoded, err
+}
+
+func TestNode_SizeFitsSerialized(t *testing.T) {
+	for _, leaf := range []bool{true, false} {
+		for _, itemSize := range []int{1, 10, 100, 255} {
+			items := make([]*Item, 0)
+			childNodes := make([]pgnum, 0)
+			for i := 0; i < 20; i++ {
+				key := append([]byte{byte(i)}, bytes.Repeat([]byte("k"), itemSize-1)...)
+				items = append(items, newItem(key, bytes.Repeat([]byte("v"), itemSize)))
+				childNodes = append(childNodes, pgnum(i+1))
+			}
+			if leaf {
+				childNodes = nil
+			} else {
+				childNodes = append(childNodes, 21)
+			}
+
+			n := newNodeForSerialization(items, childNodes)
+			decoded, err := serializeTestNode(n)
+			if err != nil {
+				t.Fatalf("leaf %v, items of %d bytes: %v", leaf, itemSize, err)
+			}
+			for i, item := range decoded.items {
+				if !bytes.Equal(item.Key, items[i].Key) || !bytes.Equal(item.Value, items[i].Value) {
+					t.Fatalf("leaf %v, items of %d bytes: item %d doesn't fit the node size", leaf, itemSize, i)
+				}
+			}
+			if len(decoded.items) != len(items) || fmt.Sprint(decoded.childNodes) != fmt.Sprint(n.childNodes) {
+				t.Fatalf("leaf %v, items of %d bytes: node doesn't fit its size", leaf, itemSize)
+			}
+		}
+	}
+}
+
+func TestDal_FindAppendSplitIndex(t *testing.T) {
+	d := fillNewDalObject(&Params{pSize: testPageSize, MinStored: 0.5, MaxStored: 0.95, AppendStored: 0.9})
+	tests := []struct {
+		name     string
+		items    int
+		itemSize int
+		expected int
+	}{
+		// too few items to keep the left node full, split like any other node
+		{name: "two items", items: 2, itemSize: 255, expected: d.findBalanceIndex(testSizedNode(2, 255))},
+		// the new right-most node gets at least the last item
+		{name: "all fit", items: 5, itemSize: 10, expected: 3},
+		// 83 items of 44 bytes and the 4 bytes of the leaf are the most that fit 90% of the page
+		{name: "full", items: 100, itemSize: 20, expected: 83},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index := d.findAppendSplitIndex(testSizedNode(test.items, test.itemSize))
+			if index != test.expected {
+				t.Fatalf("expected split index %d, got %d", test.expected, index)
+			}
+		})
+	}
+}
+
+// testSizedNode returns a leaf with count items whose keys and values have the size
+func testSizedNode(count int, size int) *node {
+	items := make([]*Item, 0, count)
+	for i := 0; i < count; i++ {
+		items = append(items, testTreeItem(i, size))
+	}
+	return newNodeForSerialization(items, nil)
+}
@@ -0,0 +1,294 @@
+package customdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LostAndFound is the collection Recover puts the items of pages it can't tie to a collection in
+const LostAndFound = "lost+found"
+
+// RecoveryReport tells what Recover salvaged from a damaged file and what it lost.
+type RecoveryReport struct {
+	// Pages is the number of pages of the damaged file
+	Pages int
+	// MetaDamaged means the collections couldn't be found, every item recovered is in LostAndFound, along with the
+	// headers of the collections
+	MetaDamaged bool
+	// FreelistDamaged means free pages couldn't be told apart, items deleted before may have come back
+	FreelistDamaged bool
+	// UnreadablePages are pages in use that couldn't be decoded, their items are lost
+	UnreadablePages []uint64
+
+	Collections []RecoveredCollection
+	// LostAndFoundItems is the number of items put in LostAndFound
+	LostAndFoundItems int
+	// LostIndexes are the indexes of the recovered collections, as "collection/index". They have to be created again
+	// with their extractors.
+	LostIndexes []string
+}
+
+// RecoveredCollection is a collection rebuilt by Recover.
+type RecoveredCollection struct {
+	Name  string
+	Items int
+	// UnreadablePages are pages of the collection whose items are lost
+	UnreadablePages int
+}
+
+// salvagedCollection is a collection found in the root collection of the damaged file
+type salvagedCollection struct {
+	header *Collection
+	items  map[string][]byte
+	report *RecoveredCollection
+}
+
+// salvager reads the pages of a damaged file without trusting the references between them
+type salvager struct {
+	d     *dal
+	pages pgnum
+	// the collection every page read through the trees belongs to, nil for pages of the root collection, rollups and
+	// indexes, whose items aren't copied
+	owners      map[pgnum]*salvagedCollection
+	free        map[pgnum]bool
+	collections []*salvagedCollection
+	report      *RecoveryReport
+}
+
+// Recover salvages the items of the damaged database file at src into a new database at dst, created with params.
+// Every page of src is decoded on its own, so the items of readable nodes survive broken nodes above them. Items are
+// grouped by the collection whose tree reaches their page, the ones of pages no readable tree reaches go to the
+// LostAndFound collection. Collection modes and rollups are restored, indexes are reported in LostIndexes.
+func Recover(src string, dst string, params *Params) (*RecoveryReport, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("recovery target %s already exists", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &salvager{
+		d:      &dal{pSize: os.Getpagesize(), file: file, meta: newEmptyMeta()},
+		owners: map[pgnum]*salvagedCollection{},
+		free:   map[pgnum]bool{},
+		report: &RecoveryReport{},
+	}
+	s.pages = pgnum(info.Size() / int64(s.d.pSize))
+	s.report.Pages = int(s.pages)
+
+	s.readMeta()
+	lostAndFound := s.scanPages()
+
+	db, err := Open(dst, params)
+	if err != nil {
+		return nil, err
+	}
+	err = s.rebuild(db, lostAndFound)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s.report, db.Close()
+}
+
+// readMeta finds the collections and free pages through the meta page, if it's readable
+func (s *salvager) readMeta() {
+	p, err := s.d.readPage(metaPageNum)
+	if err == nil {
+		err = s.d.meta.deserialize(p.data)
+	}
+	if err != nil || s.d.root == 0 || s.d.root >= s.pages {
+		s.report.MetaDamaged = true
+		s.report.FreelistDamaged = true
+		return
+	}
+
+	s.readFreelist()
+	s.walk(s.d.root, nil, func(item *Item) {
+		collection := newEmptyCollection()
+		if collection.deserialize(item) != nil {
+			return
+		}
+		found := &salvagedCollection{
+			header: collection,
+			items:  map[string][]byte{},
+			report: &RecoveredCollection{Name: string(collection.name)},
+		}
+		s.collections = append(s.collections, found)
+	})
+
+	for _, collection := range s.collections {
+		s.walk(collection.header.rootNodePage, collection, nil)
+		for _, r := range collection.header.rollups {
+			s.walk(r.tree.rootNodePage, nil, nil)
+		}
+		for _, idx := range collection.header.indexes {
+			s.walk(idx.tree.rootNodePage, nil, nil)
+		}
+	}
+}
+
+func (s *salvager) readFreelist() {
+	if s.d.freelistPage == 0 || s.d.freelistPage >= s.pages {
+		s.report.FreelistDamaged = true
+		return
+	}
+	p, err := s.d.readPage(s.d.freelistPage)
+	if err != nil {
+		s.report.FreelistDamaged = true
+		return
+	}
+	freelist := setFreeList()
+	if freelist.deserialize(p.data) != nil {
+		s.report.FreelistDamaged = true
+		return
+	}
+	for _, page := range freelist.scrapedPages {
+		s.free[page] = true
+	}
+}
+
+// walk marks the pages of a tree as owned by collection, calling visit for the items of the tree if it's not nil.
+// Below a page that can't be read, the pages are left without an owner.
+func (s *salvager) walk(page pgnum, collection *salvagedCollection, visit func(item *Item)) {
+	if page == metaPageNum || page >= s.pages || page == s.d.freelistPage || s.free[page] {
+		return
+	}
+	if _, ok := s.owners[page]; ok {
+		return
+	}
+	s.owners[page] = collection
+	node, err := s.readNode(page)
+	if err != nil {
+		return
+	}
+
+	if visit != nil {
+		for _, item := range node.items {
+			visit(item)
+		}
+	}
+	for _, child := range node.childNodes {
+		s.walk(child, collection, visit)
+	}
+}
+
+// readNode decodes a page as a node, only accepting it if its keys are in order
+func (s *salvager) readNode(page pgnum) (*node, error) {
+	p, err := s.d.readPage(page)
+	if err != nil {
+		return nil, err
+	}
+	node := newEmptyNode()
+	err = node.deserialize(p.data)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(node.items); i++ {
+		if bytes.Compare(node.items[i-1].Key, node.items[i].Key) >= 0 {
+			return nil, fmt.Errorf("%w: keys out of order", ErrCorrupted)
+		}
+	}
+	return node, nil
+}
+
+// scanPages decodes every page in use and collects its items in the collection owning it, returning the items of
+// pages without one
+func (s *salvager) scanPages() map[string][]byte {
+	lostAndFound := map[string][]byte{}
+	for page := pgnum(1); page < s.pages; page++ {
+		if page == s.d.freelistPage || s.free[page] {
+			continue
+		}
+		node, err := s.readNode(page)
+		collection, owned := s.owners[page]
+		if err != nil {
+			s.report.UnreadablePages = append(s.report.UnreadablePages, uint64(page))
+			if collection != nil {
+				collection.report.UnreadablePages++
+			}
+			continue
+		}
+		if owned && collection == nil {
+			continue
+		}
+
+		items := lostAndFound
+		if collection != nil {
+			items = collection.items
+		}
+		for _, item := range node.items {
+			items[string(item.Key)] = item.Value
+		}
+	}
+	return lostAndFound
+}
+
+// rebuild writes the salvaged collections to db, one transaction each
+func (s *salvager) rebuild(db *DB, lostAndFound map[string][]byte) error {
+	for _, collection := range s.collections {
+		header := collection.header
+		intervals := make([]time.Duration, 0, len(header.rollups))
+		for _, r := range header.rollups {
+			intervals = append(intervals, r.interval)
+		}
+		err := db.restoreCollection(header.name, header.mode, collection.items, intervals)
+		if err != nil {
+			return fmt.Errorf("can't restore collection %s: %w", header.name, err)
+		}
+		collection.report.Items = len(collection.items)
+		s.report.Collections = append(s.report.Collections, *collection.report)
+		for _, idx := range header.indexes {
+			s.report.LostIndexes = append(s.report.LostIndexes, fmt.Sprintf("%s/%s", header.name, idx.name))
+		}
+	}
+
+	if len(lostAndFound) == 0 {
+		return nil
+	}
+	err := db.restoreCollection([]byte(LostAndFound), collectionModeDefault, lostAndFound, nil)
+	if err != nil {
+		return fmt.Errorf("can't restore collection %s: %w", LostAndFound, err)
+	}
+	s.report.LostAndFoundItems = len(lostAndFound)
+	return nil
+}
+
+func (db *DB) restoreCollection(name []byte, mode collectionMode, items map[string][]byte,
+	rollups []time.Duration) error {
+	return db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(name)
+		if errors.Is(err, ErrCollectionNotFound) {
+			collection, err = tx.createNewCollection(name, mode)
+		}
+		if err != nil {
+			return err
+		}
+
+		for key, value := range items {
+			err = collection.Put([]byte(key), value)
+			if err != nil {
+				return err
+			}
+		}
+		for _, interval := range rollups {
+			err = collection.AddRollup(interval)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package customdb
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// recoverTestDB recovers the file at src into a new database and checks it
+func recoverTestDB(t *testing.T, src string) (*DB, *RecoveryReport) {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), "recovered")
+	report, err := Recover(src, dst, &Params{MinStored: testMinPercentage, MaxStored: testMaxPercentage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := openTestDB(t, dst)
+	checkTestDB(t, db)
+	return db, report
+}
+
+// findTestItems returns which of the keys the collection has
+func findTestItems(t *testing.T, db *DB, name []byte, keys [][]byte) []bool {
+	t.Helper()
+	found := make([]bool, len(keys))
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(name)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			item, err := collection.Find(key)
+			if err != nil {
+				return err
+			}
+			found[i] = item != nil
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestRecover_DamagedLeaf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	keys := putTestTreeItems(t, db, 20, bigTestItem)
+	page, lost := firstTestLeaf(t, db)
+	damageTestPage(t, db, path, page)
+
+	recovered, report := recoverTestDB(t, path)
+	defer recovered.Close()
+
+	if len(report.UnreadablePages) != 1 || report.UnreadablePages[0] != uint64(page) {
+		t.Errorf("expected page %d to be unreadable, got %v", page, report.UnreadablePages)
+	}
+	if len(report.Collections) != 1 || report.Collections[0].Items != len(keys)-lost {
+		t.Fatalf("expected %d items to be recovered, got %+v", len(keys)-lost, report.Collections)
+	}
+
+	// the damaged leaf is the left-most one, it had the first keys
+	for i, found := range findTestItems(t, recovered, testCollectionName, keys) {
+		if found != (i >= lost) {
+			t.Errorf("expected key %d to be recovered: %v", i, i >= lost)
+		}
+	}
+}
+
+func TestRecover_DamagedMeta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	keys := putTestTreeItems(t, db, 20, bigTestItem)
+	damageTestPage(t, db, path, metaPageNum)
+
+	recovered, report := recoverTestDB(t, path)
+	defer recovered.Close()
+
+	if !report.MetaDamaged {
+		t.Error("expected the meta page to be reported damaged")
+	}
+	for i, found := range findTestItems(t, recovered, []byte(LostAndFound), keys) {
+		if !found {
+			t.Errorf("expected key %d in %s", i, LostAndFound)
+		}
+	}
+}
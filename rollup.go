@@ -0,0 +1,253 @@
+package customdb
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// count, sum, min and max
+const aggregateSize = 32
+
+// rollup is a tree of pre-aggregated points of a time-series collection, one item per series and interval. It's kept
+// up to date by Put and Remove inside the same transaction that writes the point.
+type rollup struct {
+	interval time.Duration
+	tree     *Collection
+}
+
+func newRollup(owner *Collection, interval time.Duration, root pgnum) *rollup {
+	tree := newEmptyCollection()
+	tree.rootNodePage = root
+	tree.mode = collectionModeTimeSeries
+	tree.owner = owner
+	return &rollup{
+		interval: interval,
+		tree:     tree,
+	}
+}
+
+// collection returns the rollup tree in the transaction of its owner
+func (r *rollup) collection() *Collection {
+	r.tree.tx = r.tree.owner.tx
+	return r.tree
+}
+
+func (a *aggregate) serialize() []byte {
+	b := make([]byte, aggregateSize)
+	pos := 0
+	binary.LittleEndian.PutUint64(b[pos:], a.count)
+	pos += 8
+	binary.LittleEndian.PutUint64(b[pos:], math.Float64bits(a.sum))
+	pos += 8
+	binary.LittleEndian.PutUint64(b[pos:], math.Float64bits(a.min))
+	pos += 8
+	binary.LittleEndian.PutUint64(b[pos:], math.Float64bits(a.max))
+	pos += 8
+	return b
+}
+
+func deserializeAggregate(b []byte) (aggregate, error) {
+	var a aggregate
+	if len(b) != aggregateSize {
+		return a, invalidPointValueErr
+	}
+
+	pos := 0
+	a.count = binary.LittleEndian.Uint64(b[pos:])
+	pos += 8
+	a.sum = math.Float64frombits(binary.LittleEndian.Uint64(b[pos:]))
+	pos += 8
+	a.min = math.Float64frombits(binary.LittleEndian.Uint64(b[pos:]))
+	pos += 8
+	a.max = math.Float64frombits(binary.LittleEndian.Uint64(b[pos:]))
+	pos += 8
+	return a, nil
+}
+
+// Rollups returns the intervals of the rollups of the collection
+func (c *Collection) Rollups() []time.Duration {
+	intervals := make([]time.Duration, 0, len(c.rollups))
+	for _, r := range c.rollups {
+		intervals = append(intervals, r.interval)
+	}
+	return intervals
+}
+
+// AddRollup starts maintaining aggregates of the points of the collection per interval. Points already in the
+// collection are aggregated right away.
+func (c *Collection) AddRollup(interval time.Duration) error {
+	err := c.tx.writable()
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return invalidBucketErr
+	}
+	if c.rollup(interval) != nil {
+		return rollupExistsErr
+	}
+	if c.headerSize()+rollupSize > maxHeaderSize {
+		return headerTooLargeErr
+	}
+
+	r := newRollup(c, interval, 0)
+	err = c.backfillRollup(r)
+	if err != nil {
+		// nothing references the partly filled tree, give its pages back
+		freeErr := r.collection().freeTree()
+		if freeErr != nil {
+			return freeErr
+		}
+		return err
+	}
+
+	c.rollups = append(c.rollups, r)
+	return c.persistRoot()
+}
+
+func (c *Collection) rollup(interval time.Duration) *rollup {
+	for _, r := range c.rollups {
+		if r.interval == interval {
+			return r
+		}
+	}
+	return nil
+}
+
+// rollupFor returns the rollup with the biggest interval that can answer a query, nil if the raw points have to be
+// read. The buckets of the query and both ends of the range must fall on the bounds of the rollup buckets.
+func (c *Collection) rollupFor(from, to time.Time, bucket time.Duration) *rollup {
+	var best *rollup
+	for _, r := range c.rollups {
+		if bucket%r.interval != 0 || !bucketStart(from, r.interval).Equal(from) || !bucketStart(to, r.interval).Equal(to) {
+			continue
+		}
+		if best == nil || r.interval > best.interval {
+			best = r
+		}
+	}
+	return best
+}
+
+// backfillRollup aggregates the points already in the collection. Points are ordered by series and time, so the
+// buckets are filled one after another.
+func (c *Collection) backfillRollup(r *rollup) error {
+	tree := r.collection()
+
+	var current timedAggregate
+	var currentSeries []byte
+
+	flush := func() error {
+		if current.count == 0 {
+			return nil
+		}
+		key, err := encodePointKey(currentSeries, current.start)
+		if err != nil {
+			return err
+		}
+		return tree.Put(key, current.serialize())
+	}
+
+	cursor := c.Cursor()
+	item, err := cursor.First()
+	for ; err == nil && item != nil; item, err = cursor.Next() {
+		series, timestamp, decodeErr := decodePointKey(item.Key)
+		if decodeErr != nil {
+			// not a point
+			continue
+		}
+		value, decodeErr := decodePointValue(item.Value)
+		if decodeErr != nil {
+			continue
+		}
+
+		start := bucketStart(timestamp, r.interval)
+		if current.count != 0 && (!start.Equal(current.start) || string(series) != string(currentSeries)) {
+			err = flush()
+			if err != nil {
+				return err
+			}
+			current = timedAggregate{}
+		}
+		current.start = start
+		currentSeries = series
+		current.add(value)
+	}
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// updateRollups updates the buckets of a point after its item was written, or removed if value is nil. Items that
+// aren't points are ignored, like when the rollups are filled. A new point is added to its buckets, but a replaced or
+// removed one can't be subtracted from min and max, so its buckets are aggregated again from the raw points.
+func (c *Collection) updateRollups(key []byte, oldItem *Item, value []byte) error {
+	series, timestamp, err := decodePointKey(key)
+	if err != nil {
+		return nil
+	}
+	replaced := false
+	if oldItem != nil {
+		_, err = decodePointValue(oldItem.Value)
+		replaced = err == nil
+	}
+	point, err := decodePointValue(value)
+	added := err == nil
+	if !replaced && !added {
+		return nil
+	}
+
+	for _, r := range c.rollups {
+		tree := r.collection()
+		start := bucketStart(timestamp, r.interval)
+		bucketKey, err := encodePointKey(series, start)
+		if err != nil {
+			return err
+		}
+
+		var a aggregate
+		if replaced {
+			aggregates, err := c.aggregateRange(series, start, start.Add(r.interval), r.interval, decodeRollupPoint)
+			if err != nil {
+				return err
+			}
+			for _, bucketAggregate := range aggregates {
+				a.merge(bucketAggregate.aggregate)
+			}
+		} else {
+			item, err := tree.Find(bucketKey)
+			if err != nil {
+				return err
+			}
+			if item != nil {
+				a, err = deserializeAggregate(item.Value)
+				if err != nil {
+					return err
+				}
+			}
+			a.add(point)
+		}
+
+		if a.count == 0 {
+			err = tree.Remove(bucketKey)
+		} else {
+			err = tree.Put(bucketKey, a.serialize())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeRollupPoint decodes a point like decodePointAggregate, but items that aren't points count as empty, like when
+// the rollups are filled
+func decodeRollupPoint(value []byte) (aggregate, error) {
+	a, err := decodePointAggregate(value)
+	if err != nil {
+		return aggregate{}, nil
+	}
+	return a, nil
+}
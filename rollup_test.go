@@ -0,0 +1,207 @@
+package customdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSeries = []byte("cpu")
+
+// putTestPoints writes count points one second apart from start in a new time-series collection with the rollups
+func putTestPoints(t *testing.T, db *DB, start time.Time, count int, rollups ...time.Duration) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.CreateTimeSeriesCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for _, interval := range rollups {
+			err = collection.AddRollup(interval)
+			if err != nil {
+				return err
+			}
+		}
+		for i := 0; i < count; i++ {
+			err = collection.PutPoint(testSeries, start.Add(time.Duration(i)*time.Second), float64(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// aggregateTestMinute returns the bucket of the minute from start, read from the rollups if the collection has any
+func aggregateTestMinute(t *testing.T, db *DB, start time.Time) []Bucket {
+	t.Helper()
+	var buckets []Bucket
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		buckets, err = collection.Aggregate(testSeries, start, start.Add(time.Minute), time.Minute, AggregateCount)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buckets
+}
+
+func TestRollup_Remove(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	start := time.Unix(1700000040, 0)
+	putTestPoints(t, db, start, 10, time.Minute)
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		// the maximum can't be subtracted, the bucket has to be aggregated again
+		key, err := encodePointKey(testSeries, start.Add(9*time.Second))
+		if err != nil {
+			return err
+		}
+		return collection.Remove(key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := aggregateTestMinute(t, db, start)
+	if len(buckets) != 1 || buckets[0].Count != 9 || buckets[0].Max != 8 || buckets[0].Sum != 36 {
+		t.Fatalf("expected 9 points up to 8 after removing one, got %+v", buckets)
+	}
+
+	err = db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 9; i++ {
+			key, err := encodePointKey(testSeries, start.Add(time.Duration(i)*time.Second))
+			if err != nil {
+				return err
+			}
+			err = collection.Remove(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets = aggregateTestMinute(t, db, start)
+	if len(buckets) != 0 {
+		t.Fatalf("expected no bucket after removing every point, got %+v", buckets)
+	}
+	checkTestDB(t, db)
+}
+
+func TestRollup_PutPointKey(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	start := time.Unix(1700000040, 0)
+	putTestPoints(t, db, start, 10, time.Minute)
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		// replaces the maximum
+		key, err := encodePointKey(testSeries, start.Add(9*time.Second))
+		if err != nil {
+			return err
+		}
+		err = collection.Put(key, encodePointValue(-1))
+		if err != nil {
+			return err
+		}
+
+		key, err = encodePointKey(testSeries, start.Add(30*time.Second))
+		if err != nil {
+			return err
+		}
+		return collection.Put(key, encodePointValue(100))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := aggregateTestMinute(t, db, start)
+	if len(buckets) != 1 || buckets[0].Count != 11 || buckets[0].Min != -1 || buckets[0].Max != 100 {
+		t.Fatalf("expected 11 points from -1 to 100, got %+v", buckets)
+	}
+}
+
+// countdownContext is canceled after its Err was called a given number of times
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.remaining <= 0 {
+		return context.Canceled
+	}
+	ctx.remaining--
+	return nil
+}
+
+func TestRollup_AddRollupFailure(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	start := time.Unix(1700000040, 0)
+	putTestPoints(t, db, start, 1000)
+
+	ctx := &countdownContext{Context: context.Background(), remaining: 1000}
+	tx, err := db.BeginTx(ctx, &TxOptions{Writable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the scan of the points stops after the rollup tree took a few pages
+	ctx.remaining = 500
+	err = collection.AddRollup(time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the backfill to be canceled, got %v", err)
+	}
+	if len(collection.Rollups()) != 0 {
+		t.Fatalf("failed rollup is still in the collection: %v", collection.Rollups())
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		if len(collection.Rollups()) != 0 {
+			t.Errorf("failed rollup is stored in the collection header: %v", collection.Rollups())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+}
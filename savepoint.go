@@ -0,0 +1,116 @@
+package customdb
+
+// Savepoint is a state of a write transaction that RollbackTo can return to. The dirty nodes are copied, so later
+// changes of the transaction don't reach the savepoint.
+type Savepoint struct {
+	tx *Tx
+
+	dirtyNodes        map[pgnum]*node
+	pagesToDelete     []pgnum
+	allocatedPageNums int
+	root              pgnum
+	freelistState     freelistState
+	commitHooks       int
+	rollbackHooks     int
+}
+
+func copyNode(n *node) *node {
+	return &node{
+		tx:         n.tx,
+		pageNum:    n.pageNum,
+		items:      append([]*Item{}, n.items...),
+		childNodes: append([]pgnum{}, n.childNodes...),
+	}
+}
+
+func copyNodes(nodes map[pgnum]*node) map[pgnum]*node {
+	copied := make(map[pgnum]*node, len(nodes))
+	for pageNum, node := range nodes {
+		copied[pageNum] = copyNode(node)
+	}
+	return copied
+}
+
+// Savepoint remembers the current state of a write transaction.
+func (tx *Tx) Savepoint() (*Savepoint, error) {
+	err := tx.writable()
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &Savepoint{
+		tx:                tx,
+		dirtyNodes:        copyNodes(tx.dirtyNodes),
+		pagesToDelete:     append([]pgnum{}, tx.pagesToDelete...),
+		allocatedPageNums: len(tx.allocatedPageNums),
+		root:              tx.root,
+		freelistState:     tx.db.freelist.snapshot(),
+		commitHooks:       len(tx.commitHooks),
+		rollbackHooks:     len(tx.rollbackHooks),
+	}
+	tx.savepoints = append(tx.savepoints, sp)
+	return sp, nil
+}
+
+// RollbackTo undoes the changes made since the savepoint was taken. The savepoint stays usable, the ones taken after it
+// are released. Collections fetched before have to be fetched again with GetCollection.
+func (tx *Tx) RollbackTo(sp *Savepoint) error {
+	i := tx.savepointIndex(sp)
+	if i == -1 {
+		return invalidSavepointErr
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+
+	tx.dirtyNodes = copyNodes(sp.dirtyNodes)
+	tx.pagesToDelete = append([]pgnum{}, sp.pagesToDelete...)
+	tx.db.freelist.restore(sp.freelistState)
+	tx.allocatedPageNums = tx.allocatedPageNums[:sp.allocatedPageNums]
+	tx.root = sp.root
+
+	tx.commitHooks = tx.commitHooks[:sp.commitHooks]
+	tx.rolledBackHooks = append(tx.rolledBackHooks, tx.rollbackHooks[sp.rollbackHooks:]...)
+	tx.rollbackHooks = tx.rollbackHooks[:sp.rollbackHooks]
+	return nil
+}
+
+// Release forgets the savepoint and the ones taken after it, keeping the changes made since.
+func (tx *Tx) Release(sp *Savepoint) error {
+	i := tx.savepointIndex(sp)
+	if i == -1 {
+		return invalidSavepointErr
+	}
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+func (tx *Tx) savepointIndex(sp *Savepoint) int {
+	if sp == nil || sp.tx != tx {
+		return -1
+	}
+	for i, savepoint := range tx.savepoints {
+		if savepoint == sp {
+			return i
+		}
+	}
+	return -1
+}
+
+// Nested runs fn as a nested transaction: if it returns an error, its changes are rolled back and the error is
+// returned, while the changes made before stay in the transaction.
+func (tx *Tx) Nested(fn func(*Tx) error) error {
+	sp, err := tx.Savepoint()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.RollbackTo(sp)
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		_ = tx.Release(sp)
+		return err
+	}
+	return tx.Release(sp)
+}
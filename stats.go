@@ -0,0 +1,230 @@
+package customdb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counters are the running numbers behind DB.Stats, updated without taking the lock of the database
+type counters struct {
+	// page counts of the last commit, so reading them doesn't wait for a write transaction
+	pages     atomic.Int64
+	freePages atomic.Int64
+
+	readTxs      atomic.Int64
+	writeTxs     atomic.Int64
+	openReadTxs  atomic.Int64
+	openWriteTxs atomic.Int64
+
+	// nodes a transaction found among its dirty nodes and nodes it had to read from the file
+	nodeHits  atomic.Int64
+	nodeReads atomic.Int64
+
+	// commits and rollbacks of write transactions
+	commits   atomic.Int64
+	rollbacks atomic.Int64
+
+	splits     atomic.Int64
+	merges     atomic.Int64
+	pageReads  atomic.Int64
+	pageWrites atomic.Int64
+
+	lockWaits    atomic.Int64
+	lockWaitTime atomic.Int64
+}
+
+func (c *counters) pagesChanged(freelist *freelist) {
+	c.pages.Store(int64(freelist.maxAllowedPage) + 1)
+	c.freePages.Store(int64(len(freelist.scrapedPages)))
+}
+
+func (c *counters) txStarted(write bool) {
+	if write {
+		c.writeTxs.Add(1)
+		c.openWriteTxs.Add(1)
+	} else {
+		c.readTxs.Add(1)
+		c.openReadTxs.Add(1)
+	}
+}
+
+func (c *counters) txClosed(write bool) {
+	if write {
+		c.openWriteTxs.Add(-1)
+	} else {
+		c.openReadTxs.Add(-1)
+	}
+}
+
+func (c *counters) lockWaited(start time.Time) {
+	c.lockWaits.Add(1)
+	c.lockWaitTime.Add(int64(time.Since(start)))
+}
+
+// Stats describes the file of the database and what was done with it since it was opened.
+type Stats struct {
+	PageSize int
+	// Pages is the number of pages of the file, including the meta and freelist pages, as of the last commit
+	Pages     int
+	FreePages int
+	FileSize  int64
+
+	// ReadTxs and WriteTxs are the transactions started since the database was opened, Open* the ones not finished
+	ReadTxs      int64
+	WriteTxs     int64
+	OpenReadTxs  int64
+	OpenWriteTxs int64
+
+	// Commits and Rollbacks count write transactions only
+	Commits   int64
+	Rollbacks int64
+
+	// NodeHits are nodes found among the changes of their transaction, NodeReads nodes read from the file
+	NodeHits  int64
+	NodeReads int64
+	// HitRatio is NodeHits out of all the nodes transactions asked for, 0 before any was. There is no cache of pages, a
+	// hit is a node the transaction had already changed, so it tells how often transactions go back to their own
+	// changes rather than how well reads are cached.
+	HitRatio float64
+
+	Splits     int64
+	Merges     int64
+	PageReads  int64
+	PageWrites int64
+
+	// LockWaits is the number of transactions that took the lock, LockWaitTime the time they spent waiting for it
+	LockWaits    int64
+	LockWaitTime time.Duration
+}
+
+// Stats returns the statistics of the database. It doesn't wait for open transactions.
+func (db *DB) Stats() (Stats, error) {
+	if db.file == nil {
+		return Stats{}, ErrDatabaseClosed
+	}
+	info, err := db.file.Stat()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		PageSize:     db.pSize,
+		Pages:        int(db.stats.pages.Load()),
+		FreePages:    int(db.stats.freePages.Load()),
+		FileSize:     info.Size(),
+		ReadTxs:      db.stats.readTxs.Load(),
+		WriteTxs:     db.stats.writeTxs.Load(),
+		OpenReadTxs:  db.stats.openReadTxs.Load(),
+		OpenWriteTxs: db.stats.openWriteTxs.Load(),
+		Commits:      db.stats.commits.Load(),
+		Rollbacks:    db.stats.rollbacks.Load(),
+		NodeHits:     db.stats.nodeHits.Load(),
+		NodeReads:    db.stats.nodeReads.Load(),
+		Splits:       db.stats.splits.Load(),
+		Merges:       db.stats.merges.Load(),
+		PageReads:    db.stats.pageReads.Load(),
+		PageWrites:   db.stats.pageWrites.Load(),
+		LockWaits:    db.stats.lockWaits.Load(),
+		LockWaitTime: time.Duration(db.stats.lockWaitTime.Load()),
+	}
+	if requested := stats.NodeHits + stats.NodeReads; requested > 0 {
+		stats.HitRatio = float64(stats.NodeHits) / float64(requested)
+	}
+	return stats, nil
+}
+
+// CollectionStats describes the tree of a collection.
+type CollectionStats struct {
+	// Depth is the number of levels of the tree, 0 for a collection that was never written to
+	Depth int
+	// NodesPerLevel holds the node count of every level, starting at the root
+	NodesPerLevel []int
+	Nodes         int
+	Leaves        int
+
+	Keys       int
+	KeyBytes   int64
+	ValueBytes int64
+
+	// the fill of the leaves in percents of a page, and the bounds the tree keeps them in
+	MinLeafFill float64
+	AvgLeafFill float64
+	MaxLeafFill float64
+	MinStored   float64
+	MaxStored   float64
+	// UnderfilledLeaves are leaves below MinStored, the root is one until the first split
+	UnderfilledLeaves int
+}
+
+// Stats walks the tree of the collection and returns its statistics.
+func (c *Collection) Stats() (CollectionStats, error) {
+	err := c.tx.readable()
+	if err != nil {
+		return CollectionStats{}, err
+	}
+
+	stats := CollectionStats{
+		MinStored: float64(c.tx.db.minStored * 100),
+		MaxStored: float64(c.tx.db.maxStored * 100),
+	}
+	if c.rootNodePage == 0 {
+		return stats, nil
+	}
+
+	root, err := c.tx.getNode(c.rootNodePage)
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	var fillSum float64
+	err = c.collectStats(root, 0, &stats, &fillSum)
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	stats.Depth = len(stats.NodesPerLevel)
+	if stats.Leaves > 0 {
+		stats.AvgLeafFill = fillSum / float64(stats.Leaves)
+	}
+	return stats, nil
+}
+
+func (c *Collection) collectStats(node *node, level int, stats *CollectionStats, fillSum *float64) error {
+	if len(stats.NodesPerLevel) == level {
+		stats.NodesPerLevel = append(stats.NodesPerLevel, 0)
+	}
+	stats.NodesPerLevel[level]++
+	stats.Nodes++
+
+	for _, item := range node.items {
+		stats.Keys++
+		stats.KeyBytes += int64(len(item.Key))
+		stats.ValueBytes += int64(len(item.Value))
+	}
+
+	if node.isLeaf() {
+		fill := float64(node.nodeSize()) / float64(c.tx.db.pSize) * 100
+		if stats.Leaves == 0 || fill < stats.MinLeafFill {
+			stats.MinLeafFill = fill
+		}
+		if fill > stats.MaxLeafFill {
+			stats.MaxLeafFill = fill
+		}
+		if fill < stats.MinStored {
+			stats.UnderfilledLeaves++
+		}
+		*fillSum += fill
+		stats.Leaves++
+		return nil
+	}
+
+	for _, pageNum := range node.childNodes {
+		child, err := c.tx.getNode(pageNum)
+		if err != nil {
+			return err
+		}
+		err = c.collectStats(child, level+1, stats, fillSum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
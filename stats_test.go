@@ -0,0 +1,76 @@
+package customdb
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// expectTestPages fails the test unless the stats count the pages of the file and the free ones among them
+func expectTestPages(t *testing.T, db *DB, pages int, freePages int) {
+	t.Helper()
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pages != pages || stats.FreePages != freePages || stats.FileSize != int64(pages*stats.PageSize) {
+		t.Errorf("expected %d pages with %d free ones, got %+v", pages, freePages, stats)
+	}
+}
+
+func TestDB_Stats(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	// the meta and freelist pages and the root collection
+	expectTestPages(t, db, 3, 0)
+
+	keys := createTestTree(t, db, cursorTestShape)
+	expectTestPages(t, db, 3+8, 0)
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		stats, err := collection.Stats()
+		if err != nil {
+			return err
+		}
+
+		if stats.Depth != 3 || !reflect.DeepEqual(stats.NodesPerLevel, []int{1, 2, 5}) || stats.Nodes != 8 ||
+			stats.Leaves != 5 {
+			t.Errorf("expected 8 nodes on 3 levels with 5 leaves, got %+v", stats)
+		}
+		size := int64(len(keys) * bigTestItem)
+		if stats.Keys != len(keys) || stats.KeyBytes != size || stats.ValueBytes != size {
+			t.Errorf("expected %d keys and values of %d bytes, got %+v", len(keys), size, stats)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *Tx) error {
+		return tx.DeleteCollection(testCollectionName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file keeps its size, the nodes of the collection are free
+	expectTestPages(t, db, 3+8, 8)
+}
+
+func TestDB_StatsClosed(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	err := db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Stats()
+	if !errors.Is(err, ErrDatabaseClosed) {
+		t.Fatalf("expected ErrDatabaseClosed, got %v", err)
+	}
+}
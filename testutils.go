@@ -1,4 +1,4 @@
-package main
+package customdb
 
 import (
 	"bytes"
@@ -46,14 +46,14 @@ var (
 //	areTreesEqualHelper(t, t1Root, t2Root)
 //}
 //
-//func areNodesEqual(t *testing.T, n1, n2 *Node) {
+//func areNodesEqual(t *testing.T, n1, n2 *node) {
 //	for i := 0; i < len(n1.items); i++ {
 //		assert.Equal(t, n1.items[i].key, n2.items[i].key)
 //		assert.Equal(t, n1.items[i].value, n2.items[i].value)
 //	}
 //}
 //
-//func areTreesEqualHelper(t *testing.T, n1, n2 *Node) {
+//func areTreesEqualHelper(t *testing.T, n1, n2 *node) {
 //	require.Equal(t, len(n1.items), len(n2.items))
 //	require.Equal(t, len(n1.childNodes), len(n2.childNodes))
 //	areNodesEqual(t, n1, n2)
@@ -0,0 +1,100 @@
+package customdb
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+const (
+	seriesLenSize = 1
+	timestampSize = 8
+	pointValSize  = 8
+
+	// keys store their length in one byte
+	maxSeriesSize = 255 - seriesLenSize - timestampSize
+)
+
+// encodePointKey builds the key of a time-series point: series length, series name and the timestamp. The timestamp is
+// big endian with the sign bit flipped, so byte order of the keys is the time order of the points of a series.
+func encodePointKey(series []byte, timestamp time.Time) ([]byte, error) {
+	if len(series) > maxSeriesSize {
+		return nil, seriesTooLongErr
+	}
+
+	key := make([]byte, seriesLenSize+len(series)+timestampSize)
+	pos := 0
+	key[pos] = byte(len(series))
+	pos += seriesLenSize
+
+	copy(key[pos:], series)
+	pos += len(series)
+
+	binary.BigEndian.PutUint64(key[pos:], uint64(timestamp.UnixNano())^(1<<63))
+	return key, nil
+}
+
+func decodePointKey(key []byte) ([]byte, time.Time, error) {
+	if len(key) < seriesLenSize+timestampSize {
+		return nil, time.Time{}, invalidPointKeyErr
+	}
+
+	pos := 0
+	seriesLen := int(key[pos])
+	pos += seriesLenSize
+	if len(key) != seriesLenSize+seriesLen+timestampSize {
+		return nil, time.Time{}, invalidPointKeyErr
+	}
+
+	series := key[pos : pos+seriesLen]
+	pos += seriesLen
+
+	nanos := int64(binary.BigEndian.Uint64(key[pos:]) ^ (1 << 63))
+	return series, time.Unix(0, nanos), nil
+}
+
+func encodePointValue(value float64) []byte {
+	b := make([]byte, pointValSize)
+	binary.LittleEndian.PutUint64(b, math.Float64bits(value))
+	return b
+}
+
+func decodePointValue(b []byte) (float64, error) {
+	if len(b) != pointValSize {
+		return 0, invalidPointValueErr
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// PutPoint writes the value of a series at the given time. Points written in time order are appended to the end of the
+// collection, which is what time-series collections are optimized for. The rollups of the collection are updated in
+// the same transaction.
+func (c *Collection) PutPoint(series []byte, timestamp time.Time, value float64) error {
+	key, err := encodePointKey(series, timestamp)
+	if err != nil {
+		return err
+	}
+	return c.Put(key, encodePointValue(value))
+}
+
+// FindPoint returns the value of a series at the given time, false if there is no such point.
+func (c *Collection) FindPoint(series []byte, timestamp time.Time) (float64, bool, error) {
+	key, err := encodePointKey(series, timestamp)
+	if err != nil {
+		return 0, false, err
+	}
+
+	item, err := c.Find(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if item == nil {
+		return 0, false, nil
+	}
+
+	value, err := decodePointValue(item.Value)
+	if err != nil {
+		return 0, false, err
+	}
+	return value, true, nil
+}
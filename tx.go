@@ -1,43 +1,133 @@
-package main
+package customdb
 
-type tx struct {
-	dirtyNodes    map[pgnum]*Node
+import "context"
+
+// Tx is a read or write transaction. Changes of a write transaction are kept in memory until Commit.
+type Tx struct {
+	dirtyNodes    map[pgnum]*node
 	pagesToDelete []pgnum
 
 	allocatedPageNums []pgnum
 
 	write bool
+	// managed transactions are committed or rolled back by DB.Update and DB.View, not by the caller
+	managed bool
+	// set once the transaction is committed or rolled back
+	closed bool
+
+	db *DB
+
+	// root page of the root collection, written to the meta page on commit
+	root pgnum
+
+	// scans of the transaction stop once it's done
+	ctx context.Context
 
-	Database *Database
+	savepoints []*Savepoint
+
+	// freelist when the transaction started, restored on rollback
+	freelistState freelistState
+
+	commitHooks   []func()
+	rollbackHooks []func()
+	// rollback hooks of the parts undone by RollbackTo, they run however the transaction ends
+	rolledBackHooks []func()
 }
 
-func newTx(Database *Database, write bool) *tx {
-	return &tx{
-		map[pgnum]*Node{},
+func newTx(ctx context.Context, db *DB, write bool) *Tx {
+	tx := &Tx{
+		map[pgnum]*node{},
 		make([]pgnum, 0),
 		make([]pgnum, 0),
 		write,
-		Database,
+		false,
+		false,
+		db,
+		db.root,
+		ctx,
+		nil,
+		freelistState{},
+		nil,
+		nil,
+		nil,
+	}
+	if write {
+		tx.freelistState = db.freelist.snapshot()
+	}
+	db.stats.txStarted(write)
+	return tx
+}
+
+func (tx *Tx) close() {
+	tx.closed = true
+	tx.db.stats.txClosed(tx.write)
+}
+
+// OnCommit registers fn to run after the transaction is committed. Hooks run in the order they were registered, after
+// the lock of the transaction is released, so they may start other transactions. They also run when Commit returns
+// the error of a failed sync, since the changes are visible to later transactions by then.
+func (tx *Tx) OnCommit(fn func()) {
+	tx.commitHooks = append(tx.commitHooks, fn)
+}
+
+// OnRollback registers fn to run after the transaction is rolled back, by Rollback or by a failed Commit. A hook
+// registered after a savepoint also runs if RollbackTo undoes its part of the transaction.
+func (tx *Tx) OnRollback(fn func()) {
+	tx.rollbackHooks = append(tx.rollbackHooks, fn)
+}
+
+func runHooks(hooks []func()) {
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Context returns the context the transaction was started with.
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// writable returns the error a write operation of the transaction should fail with, if any
+func (tx *Tx) writable() error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	if tx.db.readOnly {
+		return ErrDatabaseReadOnly
+	}
+	if !tx.write {
+		return ErrTxNotWritable
+	}
+	return nil
+}
+
+// readable returns the error a read operation of the transaction should fail with, if any
+func (tx *Tx) readable() error {
+	if tx.closed {
+		return ErrTxClosed
 	}
+	return tx.ctx.Err()
 }
 
-func (tx *tx) newNode(items []*Item, childNodes []pgnum) *Node {
-	node := NewEmptyNode()
+func (tx *Tx) newNode(items []*Item, childNodes []pgnum) *node {
+	node := newEmptyNode()
 	node.items = items
 	node.childNodes = childNodes
-	node.pageNum = tx.Database.AllocateNewPage()
+	node.pageNum = tx.db.allocateNewPage()
 	node.tx = tx
 
 	node.tx.allocatedPageNums = append(node.tx.allocatedPageNums, node.pageNum)
 	return node
 }
 
-func (tx *tx) getNode(pageNum pgnum) (*Node, error) {
+func (tx *Tx) getNode(pageNum pgnum) (*node, error) {
 	if node, ok := tx.dirtyNodes[pageNum]; ok {
+		tx.db.stats.nodeHits.Add(1)
 		return node, nil
 	}
 
-	node, err := tx.Database.getNode(pageNum)
+	tx.db.stats.nodeReads.Add(1)
+	node, err := tx.db.getNode(pageNum)
 	if err != nil {
 		return nil, err
 	}
@@ -45,60 +135,170 @@ func (tx *tx) getNode(pageNum pgnum) (*Node, error) {
 	return node, nil
 }
 
-func (tx *tx) createNode(node *Node) *Node {
+func (tx *Tx) createNode(node *node) *node {
 	tx.dirtyNodes[node.pageNum] = node
 	node.tx = tx
 	return node
 }
 
-func (tx *tx) deleteNode(node *Node) {
+func (tx *Tx) deleteNode(node *node) {
 	tx.pagesToDelete = append(tx.pagesToDelete, node.pageNum)
 }
 
-func (tx *tx) Rollback() {
+func (tx *Tx) Rollback() error {
+	if tx.managed {
+		return managedTxErr
+	}
+	if tx.closed {
+		return ErrTxClosed
+	}
+
+	tx.rollback()
+	return nil
+}
+
+func (tx *Tx) rollback() {
+	hooks := append(tx.rolledBackHooks, tx.rollbackHooks...)
+	tx.commitHooks, tx.rollbackHooks, tx.rolledBackHooks = nil, nil, nil
+	defer runHooks(hooks)
+
+	tx.close()
 	if !tx.write {
-		tx.Database.rwlock.RUnlock()
+		tx.db.rwlock.RUnlock()
 		return
 	}
 
+	// Rollback comes before anything is written, forgetting the changes and the pages they took is enough. A commit that
+	// failed may have written some nodes already, and nodes are written over their own pages, so after abortCommit the
+	// file can hold part of the transaction: only the state in memory goes back to how it was.
+	tx.db.logger.Debug("transaction rolled back", "discardedPages", len(tx.dirtyNodes))
+	tx.db.stats.rollbacks.Add(1)
 	tx.dirtyNodes = nil
 	tx.pagesToDelete = nil
-	for _, pageNum := range tx.allocatedPageNums {
-		tx.Database.freelist.deletePage(pageNum)
-	}
+	tx.db.freelist.restore(tx.freelistState)
 	tx.allocatedPageNums = nil
-	tx.Database.rwlock.Unlock()
+	tx.savepoints = nil
+	tx.db.rwlock.Unlock()
 }
 
-func (tx *tx) Commit() error {
+func (tx *Tx) Commit() error {
+	if tx.managed {
+		return managedTxErr
+	}
+	if tx.closed {
+		return ErrTxClosed
+	}
+
+	return tx.commit()
+}
+
+func (tx *Tx) commit() error {
 	if !tx.write {
-		tx.Database.rwlock.RUnlock()
+		tx.close()
+		tx.db.rwlock.RUnlock()
+		tx.runCommitHooks()
+		return nil
+	}
+
+	// writes fail on a read-only database, so there is nothing to write
+	if tx.db.readOnly {
+		tx.close()
+		tx.db.rwlock.Unlock()
+		tx.runCommitHooks()
 		return nil
 	}
 
+	for _, pageNum := range tx.pagesToDelete {
+		tx.db.deleteNode(pageNum)
+	}
+	// the freelist is the page that may not fit, checking it first lets such a commit fail before anything is written
+	freelistPage, err := tx.db.serializeFreeList()
+	if err != nil {
+		return tx.abortCommit(err)
+	}
+
 	for _, node := range tx.dirtyNodes {
-		_, err := tx.Database.createNode(node)
+		_, err = tx.db.createNode(node)
 		if err != nil {
-			return err
+			return tx.abortCommit(err)
 		}
 	}
 
-	for _, pageNum := range tx.pagesToDelete {
-		tx.Database.deleteNode(pageNum)
-	}
-	_, err := tx.Database.updateFreeList()
+	err = tx.db.writePage(freelistPage)
 	if err != nil {
-		return err
+		return tx.abortCommit(err)
+	}
+
+	if tx.root != tx.db.root {
+		meta := *tx.db.meta
+		meta.root = tx.root
+		_, err = tx.db.updateMeta(&meta)
+		if err != nil {
+			return tx.abortCommit(err)
+		}
+		tx.db.root = tx.root
 	}
 
+	// the commit is done either way, a failed sync only means it may not survive a crash
+	if tx.db.durability == DurabilityFull {
+		err = tx.db.sync()
+	}
+
+	tx.db.stats.commits.Add(1)
+	tx.db.stats.pagesChanged(tx.db.freelist)
+	tx.db.logger.Debug("transaction committed", "writtenPages", len(tx.dirtyNodes), "freedPages", len(tx.pagesToDelete),
+		"root", tx.root)
 	tx.dirtyNodes = nil
 	tx.pagesToDelete = nil
 	tx.allocatedPageNums = nil
-	tx.Database.rwlock.Unlock()
+	tx.savepoints = nil
+	tx.close()
+	tx.db.rwlock.Unlock()
+	tx.runCommitHooks()
+	if err != nil {
+		tx.db.logger.Error("sync after commit failed", "err", err)
+		return err
+	}
 	return nil
 }
 
-//	func (tx *tx) GetCollection(name []byte) (*Collection, error) {
+// abortCommit rolls back a write transaction whose commit failed to write its pages. The pages written before the
+// failure stay written.
+func (tx *Tx) abortCommit(err error) error {
+	tx.db.logger.Error("commit failed, rolling back", "err", err)
+	tx.rollback()
+	return err
+}
+
+func (tx *Tx) runCommitHooks() {
+	hooks := append(tx.rolledBackHooks, tx.commitHooks...)
+	tx.commitHooks, tx.rollbackHooks, tx.rolledBackHooks = nil, nil, nil
+	runHooks(hooks)
+}
+
+// runManaged runs fn and commits the transaction if it returns nil. If fn returns an error or panics, the transaction
+// is rolled back, and the panic goes on after that.
+func (tx *Tx) runManaged(fn func(*Tx) error) error {
+	tx.managed = true
+	finished := false
+	defer func() {
+		if !finished {
+			tx.managed = false
+			tx.rollback()
+		}
+	}()
+
+	err := fn(tx)
+	finished = true
+	tx.managed = false
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+	return tx.commit()
+}
+
+//	func (tx *Tx) GetCollection(name []byte) (*Collection, error) {
 //		rootCollection := tx.getRootCollection()
 //		item, err := rootCollection.Find(name)
 //		if err != nil {
@@ -114,14 +314,14 @@ func (tx *tx) Commit() error {
 //		collection.tx = tx
 //		return collection, nil
 //	}
-func (tx *tx) getRootCollection() *Collection {
+func (tx *Tx) getRootCollection() *Collection {
 	rootCollection := newEmptyCollection()
-	rootCollection.rootNodePage = tx.Database.root
+	rootCollection.rootNodePage = tx.root
 	rootCollection.tx = tx
 	return rootCollection
 }
 
-func (tx *tx) GetCollection(name []byte) (*Collection, error) {
+func (tx *Tx) GetCollection(name []byte) (*Collection, error) {
 	rootCollection := tx.getRootCollection()
 	item, err := rootCollection.Find(name)
 	if err != nil {
@@ -129,65 +329,113 @@ func (tx *tx) GetCollection(name []byte) (*Collection, error) {
 	}
 
 	if item == nil {
-		return nil, nil
+		return nil, ErrCollectionNotFound
 	}
 
 	collection := newEmptyCollection()
-	collection.deserialize(item)
+	err = collection.deserialize(item)
+	if err != nil {
+		return nil, err
+	}
 	collection.tx = tx
 	return collection, nil
 }
 
-func (tx *tx) GetAllCollections(name []byte) (*Collection, error) {
-	rootCollection := tx.getRootCollection()
-	item, err := rootCollection.FindAll(name)
+// Collections returns the names of the collections in key order.
+func (tx *Tx) Collections() ([][]byte, error) {
+	var names [][]byte
+	cursor := tx.getRootCollection().Cursor()
+	item, err := cursor.First()
+	for ; err == nil && item != nil; item, err = cursor.Next() {
+		names = append(names, item.Key)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return names, nil
+}
 
-	if item == nil {
-		return nil, nil
+func (tx *Tx) CreateCollection(name []byte) (*Collection, error) {
+	err := tx.writable()
+	if err != nil {
+		return nil, err
 	}
 
-	collection := newEmptyCollection()
-	collection.deserialize(item)
-	collection.tx = tx
-	return collection, nil
+	return tx.createNewCollection(name, collectionModeDefault)
 }
 
-func (tx *tx) CreateCollection(name []byte) (*Collection, error) {
-	if !tx.write {
-		return nil, writeInsideReadTxErr
+// CreateTimeSeriesCollection creates a collection for keys that only grow, like timestamps. Inserts at the end of the
+// collection split nodes at the end, so appended nodes stay full.
+func (tx *Tx) CreateTimeSeriesCollection(name []byte) (*Collection, error) {
+	err := tx.writable()
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.createNewCollection(name, collectionModeTimeSeries)
+}
+
+func (tx *Tx) createNewCollection(name []byte, mode collectionMode) (*Collection, error) {
+	// checked before the root page is taken, which nothing would reference
+	if len(name) > maxKeySize {
+		return nil, ErrKeyTooLarge
 	}
 
-	newCollectionPage, err := tx.Database.createNode(NewEmptyNode())
+	item, err := tx.getRootCollection().Find(name)
 	if err != nil {
 		return nil, err
 	}
+	if item != nil {
+		return nil, ErrCollectionExists
+	}
+
+	// the root is written on commit like every other node of the transaction
+	newCollectionPage := tx.createNode(tx.newNode([]*Item{}, []pgnum{}))
 
 	newCollection := newEmptyCollection()
 	newCollection.name = name
 	newCollection.rootNodePage = newCollectionPage.pageNum
+	newCollection.mode = mode
 	return tx.createCollection(newCollection)
 }
 
-func (tx *tx) DeleteCollection(name []byte) error {
-	if !tx.write {
-		return writeInsideReadTxErr
+// DeleteCollection deletes the collection and gives the pages of its trees back to the freelist. If the freelist page
+// can't hold them along with the pages already free, it fails and leaves the collection as it is.
+func (tx *Tx) DeleteCollection(name []byte) error {
+	err := tx.writable()
+	if err != nil {
+		return err
 	}
 
 	rootCollection := tx.getRootCollection()
+	item, err := rootCollection.Find(name)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return ErrCollectionNotFound
+	}
 
-	return rootCollection.Remove(name)
+	collection := newEmptyCollection()
+	err = collection.deserialize(item)
+	if err != nil {
+		return err
+	}
+	collection.tx = tx
+	err = collection.freeTrees()
+	if err != nil {
+		return err
+	}
 
+	return rootCollection.Remove(name)
 }
 
-func (tx *tx) createCollection(collection *Collection) (*Collection, error) {
+func (tx *Tx) createCollection(collection *Collection) (*Collection, error) {
 	collection.tx = tx
 	collectionBytes := collection.serialize()
 
 	rootCollection := tx.getRootCollection()
-	err := rootCollection.Put(collection.name, collectionBytes.value)
+	err := rootCollection.Put(collection.name, collectionBytes.Value)
 	if err != nil {
 		return nil, err
 	}
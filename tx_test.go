@@ -0,0 +1,614 @@
+package customdb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path, &Params{MinStored: testMinPercentage, MaxStored: testMaxPercentage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// checkTestDB fails the test if DB.Check finds an inconsistency in the file
+func checkTestDB(t *testing.T, db *DB) {
+	t.Helper()
+	violations, err := db.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range violations {
+		t.Error(v)
+	}
+}
+
+func readTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestTx_RollbackCreateCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+	before := readTestFile(t, path)
+
+	tx := db.WriteTx()
+	_, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(before, readTestFile(t, path)) {
+		t.Fatal("rolled back transaction changed the file")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, path)
+	defer db.Close()
+
+	tx = db.ReadTx()
+	defer tx.Rollback()
+	_, err = tx.GetCollection(testCollectionName)
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound for a rolled back collection, got %v", err)
+	}
+}
+
+func TestTx_RollbackPut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+
+	tx := db.WriteTx()
+	collection, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = collection.Put(createItem("0"), createItem("0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := readTestFile(t, path)
+
+	// enough items to split the root, so the rollback has pages to give back
+	tx = db.WriteTx()
+	collection, err = tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"} {
+		err = collection.Put(createItem(key), createItem(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(before, readTestFile(t, path)) {
+		t.Fatal("rolled back transaction changed the file")
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, path)
+	defer db.Close()
+
+	tx = db.ReadTx()
+	defer tx.Rollback()
+	collection, err = tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"1", "5", "9"} {
+		item, err := collection.Find(createItem(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item != nil {
+			t.Fatalf("rolled back key %s exists after reopen", key)
+		}
+	}
+	item, err := collection.Find(createItem("0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil {
+		t.Fatal("committed key is missing after reopen")
+	}
+}
+
+// findTestKeys fails the test unless exactly the expected ones of the keys are in the collection
+func findTestKeys(t *testing.T, tx *Tx, expected map[string]bool) {
+	t.Helper()
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, exists := range expected {
+		item, err := collection.Find(createItem(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (item != nil) != exists {
+			t.Errorf("expected key %s to exist: %v", key, exists)
+		}
+	}
+}
+
+func putTestKeys(t *testing.T, tx *Tx, keys ...string) {
+	t.Helper()
+	collection, err := tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		err = collection.Put(createItem(key), createItem(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTx_RollbackToSavepoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db := openTestDB(t, path)
+
+	tx := db.WriteTx()
+	_, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestKeys(t, tx, "0")
+	sp, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestKeys(t, tx, "1")
+	err = tx.RollbackTo(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findTestKeys(t, tx, map[string]bool{"0": true, "1": false})
+
+	// the savepoint stays usable
+	putTestKeys(t, tx, "2")
+	err = tx.RollbackTo(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, path)
+	defer db.Close()
+	tx = db.ReadTx()
+	defer tx.Rollback()
+	findTestKeys(t, tx, map[string]bool{"0": true, "1": false, "2": false})
+}
+
+func TestTx_NestedSavepoints(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	tx := db.WriteTx()
+	defer tx.Rollback()
+	_, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestKeys(t, tx, "1")
+	inner, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestKeys(t, tx, "2")
+
+	err = tx.RollbackTo(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findTestKeys(t, tx, map[string]bool{"1": true, "2": false})
+
+	err = tx.RollbackTo(outer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findTestKeys(t, tx, map[string]bool{"1": false, "2": false})
+	// rolling back to the outer savepoint released the inner one
+	err = tx.RollbackTo(inner)
+	if !errors.Is(err, invalidSavepointErr) {
+		t.Fatalf("expected invalidSavepointErr for a released savepoint, got %v", err)
+	}
+}
+
+func TestTx_Nested(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	errFail := errors.New("fail")
+	err := db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		putTestKeys(t, tx, "0")
+
+		err = tx.Nested(func(tx *Tx) error {
+			putTestKeys(t, tx, "1")
+			err := tx.Nested(func(tx *Tx) error {
+				putTestKeys(t, tx, "2")
+				return errFail
+			})
+			if !errors.Is(err, errFail) {
+				t.Errorf("expected the error of the inner function, got %v", err)
+			}
+			findTestKeys(t, tx, map[string]bool{"1": true, "2": false})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Nested(func(tx *Tx) error {
+			putTestKeys(t, tx, "3")
+			return errFail
+		})
+		if !errors.Is(err, errFail) {
+			t.Errorf("expected the error of the function, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := db.ReadTx()
+	defer tx.Rollback()
+	findTestKeys(t, tx, map[string]bool{"0": true, "1": true, "2": false, "3": false})
+}
+
+func TestTx_ManagedCommitRollback(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	run := map[string]func(fn func(*Tx) error) error{"Update": db.Update, "View": db.View}
+	for name, managed := range run {
+		t.Run(name, func(t *testing.T) {
+			err := managed(func(tx *Tx) error {
+				err := tx.Commit()
+				if !errors.Is(err, managedTxErr) {
+					t.Errorf("expected managedTxErr from Commit, got %v", err)
+				}
+				err = tx.Rollback()
+				if !errors.Is(err, managedTxErr) {
+					t.Errorf("expected managedTxErr from Rollback, got %v", err)
+				}
+				// the refused calls leave the transaction open
+				_, err = tx.Collections()
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTx_ManagedPanic(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+	err := db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "fail" {
+				t.Errorf("expected the panic of the function to be re-raised, got %v", r)
+			}
+		}()
+		_ = db.Update(func(tx *Tx) error {
+			putTestKeys(t, tx, "1")
+			panic("fail")
+		})
+	}()
+
+	// the write lock was released by the rollback
+	err = db.Update(func(tx *Tx) error {
+		findTestKeys(t, tx, map[string]bool{"1": false})
+		putTestKeys(t, tx, "2")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.ReadTx()
+	defer tx.Rollback()
+	findTestKeys(t, tx, map[string]bool{"1": false, "2": true})
+}
+
+func TestTx_ManagedClosed(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	errFail := errors.New("fail")
+	tests := []struct {
+		name string
+		run  func(fn func(*Tx) error) error
+		err  error
+	}{
+		{name: "committed", run: db.Update},
+		{name: "rolled back", run: db.Update, err: errFail},
+		{name: "read", run: db.View},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var managed *Tx
+			err := test.run(func(tx *Tx) error {
+				managed = tx
+				return test.err
+			})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			err = managed.Commit()
+			if !errors.Is(err, ErrTxClosed) {
+				t.Errorf("expected ErrTxClosed from Commit, got %v", err)
+			}
+			err = managed.Rollback()
+			if !errors.Is(err, ErrTxClosed) {
+				t.Errorf("expected ErrTxClosed from Rollback, got %v", err)
+			}
+			_, err = managed.Collections()
+			if !errors.Is(err, ErrTxClosed) {
+				t.Errorf("expected ErrTxClosed from Collections, got %v", err)
+			}
+			_, err = managed.CreateCollection(testCollectionName)
+			if !errors.Is(err, ErrTxClosed) {
+				t.Errorf("expected ErrTxClosed from CreateCollection, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTx_RollbackToReleasesPages(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	tx := db.WriteTx()
+	_, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestKeys(t, tx, "0")
+	sp, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := db.maxAllowedPage
+
+	// enough items to split the root, so the pages of the new nodes have to be given back
+	putTestKeys(t, tx, "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	if db.maxAllowedPage == pages {
+		t.Fatal("the items took no new page")
+	}
+	err = tx.RollbackTo(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.maxAllowedPage != pages {
+		t.Fatalf("expected %d pages after rolling back, got %d", pages, db.maxAllowedPage)
+	}
+	if len(tx.dirtyNodes) > 2 {
+		t.Fatalf("expected the dirty nodes of the savepoint only, got %d", len(tx.dirtyNodes))
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTestDB(t, db)
+}
+
+func TestTx_HooksAfterFailedSync(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	errSync := errors.New("sync failed")
+	syncFile = func(*os.File) error {
+		return errSync
+	}
+	defer func() {
+		syncFile = (*os.File).Sync
+	}()
+
+	committed, rolledBack := false, false
+	tx := db.WriteTx()
+	tx.OnCommit(func() {
+		committed = true
+	})
+	tx.OnRollback(func() {
+		rolledBack = true
+	})
+	_, err := tx.CreateCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Commit()
+	if !errors.Is(err, errSync) {
+		t.Fatalf("expected the sync error, got %v", err)
+	}
+	if !committed || rolledBack {
+		t.Fatalf("expected the commit hooks only, commit hooks ran: %v, rollback hooks ran: %v", committed, rolledBack)
+	}
+
+	// the changes are visible although they may not be on the disk
+	tx = db.ReadTx()
+	defer tx.Rollback()
+	_, err = tx.GetCollection(testCollectionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTx_CreateCollectionNameTooLarge(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	err := db.Update(func(tx *Tx) error {
+		_, err := tx.CreateCollection(bytes.Repeat([]byte("c"), 300))
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("expected ErrKeyTooLarge, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+}
+
+func TestTx_DeleteCollectionFreesPages(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	defer db.Close()
+
+	putTestTreeItems(t, db, 20, bigTestItem)
+	err := db.Update(func(tx *Tx) error {
+		return tx.DeleteCollection(testCollectionName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pages of the deleted trees that weren't given back are reported as neither reachable nor free
+	checkTestDB(t, db)
+}
+
+// fullFreelistTestDB returns a database with a collection of more pages than the freelist page holds, and its keys
+func fullFreelistTestDB(t *testing.T) (*DB, [][]byte) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "db"))
+	keys := putTestTreeItems(t, db, 3000, bigTestItem)
+
+	err := db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		stats, err := collection.Stats()
+		if err != nil {
+			return err
+		}
+		if stats.Nodes <= freelistCapacity(db.pSize) {
+			t.Fatalf("expected more than %d nodes, got %d", freelistCapacity(db.pSize), stats.Nodes)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, keys
+}
+
+func TestTx_DeleteCollectionFreelistFull(t *testing.T) {
+	db, _ := fullFreelistTestDB(t)
+	defer db.Close()
+
+	err := db.Update(func(tx *Tx) error {
+		err := tx.DeleteCollection(testCollectionName)
+		if !errors.Is(err, freelistFullErr) {
+			t.Fatalf("expected freelistFullErr, got %v", err)
+		}
+		// the transaction goes on with the collection as it was
+		_, err = tx.GetCollection(testCollectionName)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDB(t, db)
+}
+
+func TestTx_CommitFreelistFull(t *testing.T) {
+	db, keys := fullFreelistTestDB(t)
+	defer db.Close()
+
+	err := db.Update(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			err = collection.Remove(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, freelistFullErr) {
+		t.Fatalf("expected freelistFullErr, got %v", err)
+	}
+
+	// the commit failed before writing anything and gave the lock back
+	checkTestDB(t, db)
+	err = db.View(func(tx *Tx) error {
+		collection, err := tx.GetCollection(testCollectionName)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			item, err := collection.Find(key)
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				t.Fatalf("key %d is missing after the failed commit", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}